@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Catalog ID for titles known to have dubbed streams
+const availableCatalogID = "ezstremio_available_cz"
+
+// availabilityEntry records the outcome of probing the providers for a title.
+type availabilityEntry struct {
+	Preview   MetaPreview `json:"preview"`
+	Available bool        `json:"available"`
+	Rank      int         `json:"rank"` // Position in the discover crawl (lower is more popular)
+}
+
+// availabilityIndex holds probe results keyed by "type:tmdbID".
+var availabilityIndex = newTTLCache[availabilityEntry]("availability")
+
+const (
+	// Titles with dubbed streams rarely lose them; titles without are re-probed sooner
+	availabilityTTLFound    = 3 * 24 * time.Hour
+	availabilityTTLNotFound = 24 * time.Hour
+	// Pause between probes so the crawl doesn't hammer the providers
+	availabilityProbeDelay = 3 * time.Second
+	// Page size of the available catalog
+	availabilityPageSize = 20
+)
+
+// startAvailabilityWorker loads the persisted index and starts the background
+// crawl that keeps it up to date.
+func startAvailabilityWorker() {
+	if Config.AvailabilityFile != "" {
+		if err := availabilityIndex.Load(Config.AvailabilityFile); err != nil {
+			slog.Warn("Failed to load availability index", "path", Config.AvailabilityFile, "err", err)
+		}
+	}
+	if Config.AvailabilityEvery <= 0 {
+		slog.Info("Availability worker disabled")
+		return
+	}
+
+	go func() {
+		for {
+			crawlAvailability()
+			time.Sleep(Config.AvailabilityEvery)
+		}
+	}()
+}
+
+// crawlAvailability probes the most popular discover titles that are not in
+// the index yet (or whose entry expired).
+func crawlAvailability() {
+	slog.Info("Availability crawl started", "pages_per_type", Config.AvailabilityPages)
+	ctx := context.Background()
+	probed, found := 0, 0
+
+	for _, catType := range []string{"movie", "series"} {
+		rank := 0
+		for page := 1; page <= Config.AvailabilityPages; page++ {
+			items, err := fetchTMDBItems(ctx, catType, "tmdb_discover", page, "", "")
+			if err != nil {
+				slog.Warn("Availability crawl: failed to fetch discover page", "type", catType, "page", page, "err", err)
+				break
+			}
+			for _, item := range items {
+				rank++
+				key := catType + ":" + item.ID
+				if _, ok := availabilityIndex.Get(key); ok {
+					continue
+				}
+
+				available := probeAvailability(ctx, catType, item)
+				ttl := availabilityTTLNotFound
+				if available {
+					ttl = availabilityTTLFound
+					found++
+				}
+				availabilityIndex.Set(key, availabilityEntry{Preview: item, Available: available, Rank: rank}, ttl)
+				probed++
+				time.Sleep(availabilityProbeDelay)
+			}
+		}
+	}
+
+	slog.Info("Availability crawl finished", "probed", probed, "dubbed", found)
+	if Config.AvailabilityFile != "" {
+		if err := availabilityIndex.Save(Config.AvailabilityFile); err != nil {
+			slog.Warn("Failed to save availability index", "path", Config.AvailabilityFile, "err", err)
+		}
+	}
+}
+
+// probeAvailability searches the providers for a title and reports whether
+// any relevant result looks dubbed.
+func probeAvailability(ctx context.Context, catType string, item MetaPreview) bool {
+	meta, err := fetchTMDBMeta(ctx, catType, strings.TrimPrefix(item.ID, "eztmdb:"))
+	if err != nil {
+		return false
+	}
+
+	names := meta.SearchNames()
+
+	for _, p := range providers {
+		if !p.Capabilities().supportsType(catType) {
+			continue
+		}
+		results, err := p.Search(ctx, meta.Name)
+		if err != nil {
+			slog.WarnContext(ctx, "Availability probe: search failed", "provider", p.Name(), "query", meta.Name, "err", err)
+			continue
+		}
+		for _, res := range p.Filter(ctx, results, meta.Year, names...) {
+			if hasLocalAudio(res.Release.AudioLangs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// availableItems returns one page of titles with known dubbed streams,
+// ordered by popularity.
+func availableItems(catType string, skip int) []MetaPreview {
+	var entries []availabilityEntry
+	for _, e := range availabilityIndex.Values() {
+		if e.Available && e.Preview.Type == catType {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Rank < entries[j].Rank
+	})
+
+	metas := []MetaPreview{}
+	for i := skip; i < len(entries) && len(metas) < availabilityPageSize; i++ {
+		metas = append(metas, entries[i].Preview)
+	}
+	return metas
+}
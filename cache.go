@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a single value stored in a ttlCache.
+type cacheEntry[V any] struct {
+	Value   V         `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// ttlCache is a simple in-memory key/value cache with per-entry expiry.
+// It can optionally be persisted to a JSON file.
+type ttlCache[V any] struct {
+	sync.RWMutex
+	name  string // Label for the cache metrics
+	m     map[string]cacheEntry[V]
+	dirty bool
+}
+
+func newTTLCache[V any](name string) *ttlCache[V] {
+	return &ttlCache[V]{name: name, m: make(map[string]cacheEntry[V])}
+}
+
+// Get returns the cached value for key if present and not expired.
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	c.RLock()
+	e, ok := c.m[key]
+	c.RUnlock()
+	if !ok || time.Now().After(e.Expires) {
+		cacheRequests.WithLabelValues(c.name, "miss").Inc()
+		var zero V
+		return zero, false
+	}
+	cacheRequests.WithLabelValues(c.name, "hit").Inc()
+	return e.Value, true
+}
+
+// Set stores value under key for the given duration.
+func (c *ttlCache[V]) Set(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.Lock()
+	c.m[key] = cacheEntry[V]{Value: value, Expires: time.Now().Add(ttl)}
+	c.dirty = true
+	c.Unlock()
+}
+
+// Values returns a snapshot of all unexpired entries.
+func (c *ttlCache[V]) Values() map[string]V {
+	now := time.Now()
+	c.RLock()
+	defer c.RUnlock()
+	values := make(map[string]V, len(c.m))
+	for k, e := range c.m {
+		if now.Before(e.Expires) {
+			values[k] = e.Value
+		}
+	}
+	return values
+}
+
+// Delete removes key from the cache.
+func (c *ttlCache[V]) Delete(key string) {
+	c.Lock()
+	if _, ok := c.m[key]; ok {
+		delete(c.m, key)
+		c.dirty = true
+	}
+	c.Unlock()
+}
+
+// Prune drops all expired entries.
+func (c *ttlCache[V]) Prune() {
+	now := time.Now()
+	c.Lock()
+	for k, e := range c.m {
+		if now.After(e.Expires) {
+			delete(c.m, k)
+			c.dirty = true
+		}
+	}
+	c.Unlock()
+}
+
+// Load reads previously saved entries from path. A missing file is not an error.
+func (c *ttlCache[V]) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries map[string]cacheEntry[V]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.Lock()
+	defer c.Unlock()
+	for k, e := range entries {
+		if now.Before(e.Expires) {
+			c.m[k] = e
+		}
+	}
+	return nil
+}
+
+// Save writes all unexpired entries to path if anything changed since the
+// last save. The file is written atomically via a temporary file.
+func (c *ttlCache[V]) Save(path string) error {
+	c.Prune()
+
+	c.Lock()
+	if !c.dirty {
+		c.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(c.m)
+	c.dirty = false
+	c.Unlock()
+	if err == nil {
+		err = writeFileAtomic(path, data, 0o644)
+	}
+	if err != nil {
+		// Retry on the next save
+		c.Lock()
+		c.dirty = true
+		c.Unlock()
+	}
+	return err
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+var configureTemplate = template.Must(template.New("configure").Parse(`<!DOCTYPE html>
+<html lang="cs">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}} – Configure</title>
+<style>
+body { font-family: sans-serif; background: #1b1b2f; color: #eee; max-width: 32em; margin: 2em auto; padding: 0 1em; }
+fieldset { border: 1px solid #444; margin-bottom: 1em; }
+label { display: block; margin: .3em 0; }
+input[type=number], select { width: 8em; }
+a.button, button { display: inline-block; background: #7b5bf5; color: #fff; padding: .6em 1.2em; border: 0; border-radius: 4px; text-decoration: none; cursor: pointer; }
+code { word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{.Description}}</p>
+<form id="config">
+<fieldset>
+<legend>Audio</legend>
+<label><input type="checkbox" name="audio" value="cs"{{if .Audio.cs}} checked{{end}}> CZ</label>
+<label><input type="checkbox" name="audio" value="sk"{{if .Audio.sk}} checked{{end}}> SK</label>
+<label><input type="checkbox" name="audio" value="en"{{if .Audio.en}} checked{{end}}> EN</label>
+<small>Nothing checked shows all streams.</small>
+<label>Preferred:
+<select name="prefer">
+<option value="">–</option>
+<option value="cs"{{if eq .Config.Prefer "cs"}} selected{{end}}>CZ</option>
+<option value="sk"{{if eq .Config.Prefer "sk"}} selected{{end}}>SK</option>
+</select></label>
+</fieldset>
+<fieldset>
+<legend>Quality</legend>
+<label>Minimum resolution:
+<select name="minHeight">
+<option value="0">–</option>
+<option value="720"{{if eq .Config.MinHeight 720}} selected{{end}}>720p</option>
+<option value="1080"{{if eq .Config.MinHeight 1080}} selected{{end}}>1080p</option>
+<option value="2160"{{if eq .Config.MinHeight 2160}} selected{{end}}>4K</option>
+</select></label>
+<label>Max file size (GB, 0 = no limit): <input type="number" name="maxSizeGB" min="0" step="0.5" value="{{.Config.MaxSizeGB}}"></label>
+</fieldset>
+<button type="submit">Install</button>
+</form>
+<p id="result" hidden>Install URL: <code id="url"></code></p>
+<script>
+document.getElementById("config").addEventListener("submit", function (e) {
+	e.preventDefault();
+	var form = e.target;
+	var cfg = {};
+	var audio = Array.from(form.querySelectorAll("input[name=audio]:checked")).map(function (i) { return i.value; });
+	if (audio.length) cfg.audio = audio;
+	if (form.prefer.value) cfg.prefer = form.prefer.value;
+	if (+form.minHeight.value) cfg.minHeight = +form.minHeight.value;
+	if (+form.maxSizeGB.value) cfg.maxSizeGB = +form.maxSizeGB.value;
+	var encoded = btoa(JSON.stringify(cfg)).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	var url = location.host + "/" + encoded + "/manifest.json";
+	document.getElementById("url").textContent = location.protocol + "//" + url;
+	document.getElementById("result").hidden = false;
+	location.href = "stremio://" + url;
+});
+</script>
+</body>
+</html>
+`))
+
+// handleConfigure serves the page where users pick their preferences and
+// install the addon with a config segment in its URL.
+func handleConfigure(w http.ResponseWriter, r *http.Request) {
+	cfg := userConfigFrom(r)
+	audio := make(map[string]bool)
+	for _, l := range cfg.Audio {
+		audio[l] = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := configureTemplate.Execute(w, map[string]interface{}{
+		"Name":        manifest.Name,
+		"Description": manifest.Description,
+		"Config":      cfg,
+		"Audio":       audio,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rendering configure page", "err", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const fakeTMDBApiKey = "test-key"
+
+// fakeUpstreams are local stand-ins for TMDB and Prehraj.to serving the
+// recorded responses in testdata/.
+//
+// TMDB paths map to file names by replacing "/" with "_", e.g.
+// /3/tv/1396/season/1 is served from testdata/tmdb/tv_1396_season_1.json.
+// Every Prehraj.to search returns searchFixture, and a video page
+// /{slug}/{id} is served from testdata/prehraj/video_{id}.html.
+type fakeUpstreams struct {
+	TMDB    *httptest.Server
+	Prehraj *httptest.Server
+
+	mu              sync.Mutex
+	searchFixture   string
+	prehrajRequests []string
+	tmdbRequests    []string
+}
+
+// newFakeUpstreams starts the fake servers and points the addon at them.
+// Global state (base URLs, Config, caches, the Prehraj.to client) is restored
+// when the test ends.
+func newFakeUpstreams(t *testing.T, searchFixture string) *fakeUpstreams {
+	t.Helper()
+	f := &fakeUpstreams{searchFixture: searchFixture}
+	f.TMDB = httptest.NewServer(http.HandlerFunc(f.serveTMDB))
+	f.Prehraj = httptest.NewServer(http.HandlerFunc(f.servePrehraj))
+	t.Cleanup(f.TMDB.Close)
+	t.Cleanup(f.Prehraj.Close)
+
+	// Anonymous session, no login attempts
+	t.Setenv("PREHRAJ_EMAIL", "")
+	t.Setenv("PREHRAJ_PASSWORD", "")
+
+	savedTMDB, savedPrehraj, savedClient, savedConfig := tmdbBaseURL, prehrajBaseURL, prehrajClient, Config
+	savedMeta, savedStreams, savedIMDb := metaCache, streamCache, imdbCache
+	savedReport := scraperHealth.report
+	t.Cleanup(func() {
+		tmdbBaseURL, prehrajBaseURL, prehrajClient, Config = savedTMDB, savedPrehraj, savedClient, savedConfig
+		metaCache, streamCache, imdbCache = savedMeta, savedStreams, savedIMDb
+		scraperHealth.report = savedReport
+	})
+	scraperHealth.report = nil
+
+	tmdbBaseURL = f.TMDB.URL + "/3"
+	prehrajBaseURL = f.Prehraj.URL
+	prehrajClient = &http.Client{Jar: prehrajJar, Timeout: 5 * time.Second}
+	metaCache = newTTLCache[metaCacheEntry]("meta")
+	streamCache = newTTLCache[[]Stream]("stream")
+	imdbCache = newTTLCache[string]("imdb")
+
+	Config.TMDBApiKey = fakeTMDBApiKey
+	Config.MetaCacheTTL = time.Hour
+	Config.MetaCacheTTLEnded = time.Hour
+	Config.StreamCacheTTL = 20 * time.Minute
+	Config.AudioFilter = nil
+	Config.ScoreWeights = parseScoreWeights("")
+	Config.SimilarityThreshold = 0.5
+	Config.ProxyStreams = false
+	Config.SubtitlesDir = ""
+	return f
+}
+
+func (f *fakeUpstreams) serveTMDB(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.tmdbRequests = append(f.tmdbRequests, r.URL.RequestURI())
+	f.mu.Unlock()
+
+	if r.URL.Query().Get("api_key") != fakeTMDBApiKey {
+		http.Error(w, `{"status_message":"Invalid API key"}`, http.StatusUnauthorized)
+		return
+	}
+	name := strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/3/"), "/", "_") + ".json"
+	data, err := os.ReadFile(filepath.Join("testdata", "tmdb", name))
+	if err != nil {
+		http.Error(w, `{"status_message":"The resource you requested could not be found."}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (f *fakeUpstreams) servePrehraj(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.prehrajRequests = append(f.prehrajRequests, r.URL.Path)
+	fixture := f.searchFixture
+	f.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, "/hledej/") {
+		fixture = "video_" + path.Base(r.URL.Path) + ".html"
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "prehraj", fixture))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(data)
+}
+
+// requested reports whether a Prehraj.to path was fetched.
+func (f *fakeUpstreams) requested(p string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.prehrajRequests {
+		if r == p {
+			return true
+		}
+	}
+	return false
+}
+
+// tmdbRequested returns the TMDB requests (path and query) whose path is p.
+func (f *fakeUpstreams) tmdbRequested(p string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var queries []url.Values
+	for _, r := range f.tmdbRequests {
+		if u, err := url.Parse(r); err == nil && u.Path == p {
+			queries = append(queries, u.Query())
+		}
+	}
+	return queries
+}
+
+// requestCount returns the number of Prehraj.to requests so far.
+func (f *fakeUpstreams) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.prehrajRequests)
+}
+
+// getJSON requests path from the addon router and decodes the JSON response.
+func getJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", path, rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s: decoding response: %v", path, err)
+	}
+}
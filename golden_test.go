@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Regenerate the golden files from the saved pages:
+//
+//	go test -run TestPrehrajGolden -update
+//
+// Download fresh pages from prehraj.to first, then regenerate (anonymous
+// session, review the diff before committing):
+//
+//	go test -run TestPrehrajGolden -refresh
+var (
+	updateGolden    = flag.Bool("update", false, "rewrite golden files in testdata/prehraj/golden")
+	refreshFixtures = flag.Bool("refresh", false, "download the pages in testdata/prehraj/golden/fixtures.json from prehraj.to and rewrite golden files")
+)
+
+const goldenDir = "testdata/prehraj/golden"
+
+// goldenFixture is an entry of fixtures.json.
+type goldenFixture struct {
+	File          string `json:"file"`
+	Query         string `json:"query,omitempty"`         // Search pages: refreshed from /hledej/{query}
+	URL           string `json:"url,omitempty"`           // Video pages: page URL (Referer, relative links)
+	FirstResultOf string `json:"firstResultOf,omitempty"` // Video pages: refreshed from the first result of this search page
+}
+
+// goldenStream is the golden form of a Stream, including the fields hidden
+// from Stremio.
+type goldenStream struct {
+	Name          string               `json:"name"`
+	Title         string               `json:"title"`
+	URL           string               `json:"url"`
+	SourceHeight  int                  `json:"sourceHeight,omitempty"`
+	Subtitles     []Subtitle           `json:"subtitles,omitempty"`
+	BehaviorHints *StreamBehaviorHints `json:"behaviorHints,omitempty"`
+}
+
+type goldenVideoPage struct {
+	Streams []goldenStream `json:"streams"`
+	Error   string         `json:"error,omitempty"`
+}
+
+func TestPrehrajGolden(t *testing.T) {
+	fixtures := loadGoldenFixtures(t)
+	if *refreshFixtures {
+		refreshGoldenFixtures(t, fixtures)
+	}
+
+	for _, fx := range fixtures {
+		t.Run(fx.File, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join(goldenDir, fx.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got interface{}
+			if strings.HasPrefix(fx.File, "search_") {
+				results := parseSearchPage(context.Background(), doc)
+				if results == nil {
+					results = []SearchResult{}
+				}
+				got = results
+			} else {
+				streams, err := parseVideoPage(doc, string(body), fx.URL)
+				page := goldenVideoPage{Streams: []goldenStream{}}
+				if err != nil {
+					page.Error = err.Error()
+				}
+				for _, s := range streams {
+					page.Streams = append(page.Streams, goldenStream{
+						Name:          s.Name,
+						Title:         s.Title,
+						URL:           s.URL,
+						SourceHeight:  s.Attrs.SourceHeight,
+						Subtitles:     s.Subtitles,
+						BehaviorHints: s.BehaviorHints,
+					})
+				}
+				got = page
+			}
+			compareGolden(t, strings.TrimSuffix(fx.File, ".html")+".golden.json", got)
+		})
+	}
+}
+
+func loadGoldenFixtures(t *testing.T) []goldenFixture {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(goldenDir, "fixtures.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []goldenFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("fixtures.json: %v", err)
+	}
+	return fixtures
+}
+
+// compareGolden checks got against the golden file, or rewrites it with -update/-refresh.
+func compareGolden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // Keep "&" in URLs readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(got); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	path := filepath.Join(goldenDir, name)
+	if *updateGolden || *refreshFixtures {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(want, data) {
+		t.Errorf("parsed output differs from %s (markup change?)\n--- got ---\n%s\n--- want ---\n%s", path, data, want)
+	}
+}
+
+// refreshGoldenFixtures downloads the live pages for fixtures that have a
+// query or firstResultOf and updates fixtures.json with the new video URLs.
+// Hand-made fixtures (neither set) are kept as they are.
+func refreshGoldenFixtures(t *testing.T, fixtures []goldenFixture) {
+	t.Helper()
+	client := &http.Client{Timeout: 30 * time.Second}
+	fetch := func(pageURL string) []byte {
+		req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", prehrajUserAgent)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %s", pageURL, resp.Status)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	save := func(file string, body []byte) {
+		if err := os.WriteFile(filepath.Join(goldenDir, file), body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("refreshed %s", file)
+	}
+
+	// Searches first, video pages are picked from their results
+	for _, fx := range fixtures {
+		if fx.Query != "" {
+			save(fx.File, fetch(fmt.Sprintf("%s/hledej/%s", prehrajBaseURL, url.PathEscape(fx.Query))))
+		}
+	}
+	for i, fx := range fixtures {
+		if fx.FirstResultOf == "" {
+			continue
+		}
+		searchPage, err := os.ReadFile(filepath.Join(goldenDir, fx.FirstResultOf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(searchPage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := parseSearchPage(context.Background(), doc)
+		if len(results) == 0 {
+			t.Fatalf("%s: no results to refresh %s from", fx.FirstResultOf, fx.File)
+		}
+		fixtures[i].URL = results[0].URL
+		save(fx.File, fetch(results[0].URL))
+	}
+
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(goldenDir, "fixtures.json"), append(data, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Scraper canary stages, in the order they run
+const (
+	stageLogin       = "login"        // Session is logged in (only with credentials)
+	stageSearch      = "search"       // Search page could be fetched
+	stageSearchParse = "search_parse" // Result selector matches and results parse
+	stageSources     = "sources"      // "var sources" regex finds streams on a video page
+	stageResolution  = "resolution"   // "Rozlišení" is parsed from the video page
+)
+
+// Video pages tried before the sources stage is reported as failed
+const canaryMaxVideos = 3
+
+// Minimum age of the last report before ?refresh=true runs the canary again,
+// so callers can't make the addon hammer Prehraj.to
+const canaryMinRefreshAge = time.Minute
+
+// canaryStage is the outcome of one stage of the scraper canary.
+type canaryStage struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok", "failed" or "skipped"
+	Detail string `json:"detail,omitempty"`
+}
+
+// canaryReport is the JSON served by /health/scraper.
+type canaryReport struct {
+	OK          bool          `json:"ok"`
+	FailedStage string        `json:"failedStage,omitempty"`
+	Query       string        `json:"query"`
+	CheckedAt   time.Time     `json:"checkedAt"`
+	DurationMs  int64         `json:"durationMs"`
+	Stages      []canaryStage `json:"stages"`
+}
+
+// Last canary report; runMu keeps periodic and on-demand runs from overlapping.
+var scraperHealth struct {
+	sync.RWMutex
+	report *canaryReport
+	runMu  sync.Mutex
+}
+
+// startScraperCanary runs the canary every Config.CanaryEvery in the background.
+func startScraperCanary() {
+	if Config.CanaryEvery <= 0 {
+		slog.Info("Scraper canary disabled")
+		return
+	}
+
+	go func() {
+		for {
+			scraperReport(context.Background(), 0)
+			time.Sleep(Config.CanaryEvery)
+		}
+	}()
+}
+
+// runScraperCanary searches for Config.CanaryQuery, opens the first results
+// and records which stage of the scraper (if any) no longer works. The caller
+// must hold scraperHealth.runMu.
+func runScraperCanary(ctx context.Context) *canaryReport {
+	start := time.Now()
+	report := &canaryReport{Query: Config.CanaryQuery, CheckedAt: start}
+	report.check(ctx)
+
+	report.OK = report.FailedStage == ""
+	report.DurationMs = time.Since(start).Milliseconds()
+	if report.OK {
+		slog.InfoContext(ctx, "Scraper canary OK", "duration_ms", report.DurationMs)
+		canaryOK.WithLabelValues().Set(1)
+	} else {
+		slog.ErrorContext(ctx, "Scraper canary failed", "stage", report.FailedStage)
+		canaryOK.WithLabelValues().Set(0)
+		canaryFailures.WithLabelValues(report.FailedStage).Inc()
+	}
+
+	scraperHealth.Lock()
+	scraperHealth.report = report
+	scraperHealth.Unlock()
+	return report
+}
+
+// scraperReport returns the last canary report if it is younger than maxAge
+// (or exists at all, for a negative maxAge), and runs the canary otherwise.
+// Concurrent callers wait for a single run.
+func scraperReport(ctx context.Context, maxAge time.Duration) *canaryReport {
+	fresh := func() *canaryReport {
+		scraperHealth.RLock()
+		defer scraperHealth.RUnlock()
+		if r := scraperHealth.report; r != nil && (maxAge < 0 || time.Since(r.CheckedAt) < maxAge) {
+			return r
+		}
+		return nil
+	}
+	if report := fresh(); report != nil {
+		return report
+	}
+
+	scraperHealth.runMu.Lock()
+	defer scraperHealth.runMu.Unlock()
+	// Another caller may have run the canary while we waited
+	if report := fresh(); report != nil {
+		return report
+	}
+	return runScraperCanary(ctx)
+}
+
+// add records the outcome of a stage; the first failure becomes FailedStage.
+func (r *canaryReport) add(name, status, detail string) {
+	r.Stages = append(r.Stages, canaryStage{Name: name, Status: status, Detail: detail})
+	if status == "failed" && r.FailedStage == "" {
+		r.FailedStage = name
+	}
+}
+
+// skip records stages that could not run because an earlier one failed.
+func (r *canaryReport) skip(names ...string) {
+	for _, n := range names {
+		r.add(n, "skipped", "")
+	}
+}
+
+// check runs the canary stages.
+func (r *canaryReport) check(ctx context.Context) {
+	// Search first, the search page also shows whether the session is logged in
+	searchURL := fmt.Sprintf("%s/hledej/%s", prehrajBaseURL, url.PathEscape(Config.CanaryQuery))
+	doc, _, err := fetchPrehrajPage(ctx, searchURL)
+
+	if _, _, ok := prehrajCredentials(); !ok {
+		r.add(stageLogin, "skipped", "no credentials configured")
+	} else if !prehrajSession.LoggedIn() {
+		r.add(stageLogin, "failed", "not logged in")
+	} else if err == nil && pageLooksLoggedOut(doc) {
+		r.add(stageLogin, "failed", "search page shows the login form")
+	} else {
+		r.add(stageLogin, "ok", "")
+	}
+
+	if err != nil {
+		r.add(stageSearch, "failed", err.Error())
+		r.skip(stageSearchParse, stageSources, stageResolution)
+		return
+	}
+	r.add(stageSearch, "ok", "")
+
+	matches := doc.Find(prehrajResultSelector).Length()
+	results := parseSearchPage(ctx, doc)
+	switch {
+	case len(results) == 0:
+		r.add(stageSearchParse, "failed", fmt.Sprintf("no results (%q matched %d links)", prehrajResultSelector, matches))
+		r.skip(stageSources, stageResolution)
+		return
+	case matches == 0:
+		// The generic fallback still works, but the primary selector is gone
+		r.add(stageSearchParse, "failed", fmt.Sprintf("%q matched nothing, %d results via fallback", prehrajResultSelector, len(results)))
+	default:
+		r.add(stageSearchParse, "ok", fmt.Sprintf("%d results", len(results)))
+	}
+
+	var streams []Stream
+	var lastErr error
+	tried := min(len(results), canaryMaxVideos)
+	for i := 0; i < tried; i++ {
+		streams, lastErr = extractPrehrajStreams(ctx, results[i].URL)
+		if lastErr == nil && len(streams) > 0 {
+			break
+		}
+	}
+	if len(streams) == 0 {
+		r.add(stageSources, "failed", fmt.Sprintf("no streams on the first %d video pages: %v", tried, lastErr))
+		r.skip(stageResolution)
+		return
+	}
+	r.add(stageSources, "ok", fmt.Sprintf("%d streams", len(streams)))
+
+	if streams[0].Attrs.SourceHeight == 0 {
+		r.add(stageResolution, "failed", "no \"Rozlišení\" value on the video page")
+		return
+	}
+	r.add(stageResolution, "ok", fmt.Sprintf("%dp", streams[0].Attrs.SourceHeight))
+}
+
+// handleScraperHealth serves the last canary report. It runs the canary first
+// if there is no report yet, or with ?refresh=true if the report is older than
+// canaryMinRefreshAge. Responds with 503 when
+// a stage failed, so it can be used by uptime monitors.
+func handleScraperHealth(w http.ResponseWriter, r *http.Request) {
+	maxAge := time.Duration(-1) // Any report, run only if there is none
+	if r.URL.Query().Get("refresh") == "true" {
+		maxAge = canaryMinRefreshAge
+	}
+	report := scraperReport(r.Context(), maxAge)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
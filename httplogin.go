@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Login modes selectable via PREHRAJ_LOGIN_MODE
+const (
+	loginModeHTTP    = "http"    // Plain form submission (default)
+	loginModeBrowser = "browser" // Headless Chromium via rod
+	loginModeAuto    = "auto"    // HTTP, falling back to the browser
+)
+
+// Login forms Prehraj.to renders (inline on the homepage, and in the login dialog)
+var loginFormSelectors = []string{
+	"#frm-homepageLoginForm-loginForm",
+	"#frm-loginDialog-login-loginForm",
+}
+
+// prehrajLogin logs in using the configured login mode and returns the
+// session cookies.
+func prehrajLogin(email, password string) ([]*http.Cookie, error) {
+	mode := strings.ToLower(os.Getenv("PREHRAJ_LOGIN_MODE"))
+	switch mode {
+	case loginModeBrowser:
+		return browserLogin(email, password)
+	case loginModeAuto:
+		cookies, err := httpLogin(email, password)
+		if err == nil {
+			return cookies, nil
+		}
+		slog.Warn("HTTP login failed, falling back to browser login", "err", err)
+		return browserLogin(email, password)
+	case "", loginModeHTTP:
+		return httpLogin(email, password)
+	default:
+		return nil, fmt.Errorf("unknown PREHRAJ_LOGIN_MODE: %s", mode)
+	}
+}
+
+// httpLogin logs into Prehraj.to by submitting the Nette login form with
+// net/http, without a browser.
+func httpLogin(email, password string) ([]*http.Cookie, error) {
+	jar := newRecordingJar()
+	client := &http.Client{
+		Jar:       jar,
+		Timeout:   30 * time.Second,
+		Transport: newUpstreamTransport("prehraj", nil),
+	}
+	base, _ := url.Parse(prehrajBaseURL + "/")
+
+	doc, err := fetchLoginPage(client, base.String())
+	if err != nil {
+		return nil, err
+	}
+
+	form := findLoginForm(doc)
+	if form == nil {
+		if !pageLooksLoggedOut(doc) {
+			return nil, fmt.Errorf("login form not found, layout changed?")
+		}
+		// Only the dialog button is rendered, the dialog itself loads via its signal URL
+		href, ok := doc.Find(`[data-dialog-open="login"]`).First().Attr("href")
+		if !ok || href == "" || href == "#" {
+			return nil, fmt.Errorf("login form not found")
+		}
+		dialogURL, err := base.Parse(href)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = fetchLoginPage(client, dialogURL.String()); err != nil {
+			return nil, err
+		}
+		if form = findLoginForm(doc); form == nil {
+			return nil, fmt.Errorf("login form not found in dialog")
+		}
+	}
+
+	// Nette forms carry hidden fields (_do, _token_, ...) that must be sent back
+	values := url.Values{}
+	form.Find("input").Each(func(i int, s *goquery.Selection) {
+		name, ok := s.Attr("name")
+		if !ok || name == "" {
+			return
+		}
+		inputType, _ := s.Attr("type")
+		if inputType == "checkbox" || inputType == "radio" {
+			if _, checked := s.Attr("checked"); !checked {
+				return
+			}
+		}
+		value, _ := s.Attr("value")
+		values.Set(name, value)
+	})
+	values.Set("email", email)
+	values.Set("password", password)
+	// The submit button's name is how Nette knows which button was pressed
+	submitName := "login"
+	if name, ok := form.Find(`button[type="submit"], input[type="submit"]`).First().Attr("name"); ok && name != "" {
+		submitName = name
+	}
+	values.Set(submitName, "")
+
+	action, _ := form.Attr("action")
+	actionURL, err := base.Parse(action)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", actionURL.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", prehrajUserAgent)
+	req.Header.Set("Referer", base.String())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login returned status: %s", resp.Status)
+	}
+	result, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if pageLooksLoggedOut(result) {
+		return nil, fmt.Errorf("still logged out after submitting the form (wrong credentials?)")
+	}
+
+	slog.Debug("Logged in via HTTP form")
+	return jar.receivedCookies(base), nil
+}
+
+// recordingJar is a cookie jar that also keeps the cookies as received in
+// Set-Cookie headers. CookieJar.Cookies only returns names and values, but
+// the saved session needs Expires, Path, Secure, ... as well.
+type recordingJar struct {
+	http.CookieJar
+	mu       sync.Mutex
+	received map[string]*http.Cookie // By name
+}
+
+func newRecordingJar() *recordingJar {
+	jar, _ := cookiejar.New(nil)
+	return &recordingJar{CookieJar: jar, received: make(map[string]*http.Cookie)}
+}
+
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.CookieJar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		rc := *c
+		if rc.MaxAge > 0 {
+			// Max-Age wins over Expires, and is relative to now
+			rc.Expires = time.Now().Add(time.Duration(rc.MaxAge) * time.Second)
+		}
+		j.received[c.Name] = &rc
+	}
+}
+
+// receivedCookies returns the cookies the jar would send to u, with all the
+// attributes they were set with.
+func (j *recordingJar) receivedCookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var cookies []*http.Cookie
+	for _, c := range j.Cookies(u) {
+		if rc, ok := j.received[c.Name]; ok && rc.Value == c.Value {
+			c = rc
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies
+}
+
+func fetchLoginPage(client *http.Client, pageURL string) (*goquery.Document, error) {
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", prehrajUserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login page returned status: %s", resp.Status)
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
+func findLoginForm(doc *goquery.Document) *goquery.Selection {
+	for _, sel := range loginFormSelectors {
+		if form := doc.Find(sel).First(); form.Length() > 0 {
+			return form
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TMDBFindResponse for resolving external (IMDb) IDs via /find
+type TMDBFindResponse struct {
+	MovieResults []struct {
+		ID int `json:"id"`
+	} `json:"movie_results"`
+	TVResults []struct {
+		ID int `json:"id"`
+	} `json:"tv_results"`
+}
+
+// imdbCache maps "type:ttID" to TMDB IDs. The mapping practically never changes.
+var imdbCache = newTTLCache[string]("imdb")
+
+const imdbCacheTTL = 30 * 24 * time.Hour
+
+var reIMDbID = regexp.MustCompile(`^tt\d+$`)
+
+// resolveIMDbID looks up the TMDB ID for an IMDb ID (e.g. "tt0944947").
+func resolveIMDbID(metaType, imdbID string) (string, error) {
+	key := metaType + ":" + imdbID
+	if tmdbID, ok := imdbCache.Get(key); ok {
+		return tmdbID, nil
+	}
+
+	if Config.TMDBApiKey == "" {
+		return "", fmt.Errorf("TMDB API Key missing")
+	}
+
+	url := fmt.Sprintf("%s/find/%s?api_key=%s&external_source=imdb_id", tmdbBaseURL, imdbID, Config.TMDBApiKey)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("TMDB returned status: %s", resp.Status)
+	}
+
+	var findResp TMDBFindResponse
+	if err := json.NewDecoder(resp.Body).Decode(&findResp); err != nil {
+		return "", err
+	}
+
+	id := 0
+	if metaType == "series" {
+		if len(findResp.TVResults) > 0 {
+			id = findResp.TVResults[0].ID
+		}
+	} else if len(findResp.MovieResults) > 0 {
+		id = findResp.MovieResults[0].ID
+	}
+	if id == 0 {
+		return "", fmt.Errorf("no TMDB %s found for %s", metaType, imdbID)
+	}
+
+	tmdbID := strconv.Itoa(id)
+	imdbCache.Set(key, tmdbID, imdbCacheTTL)
+	return tmdbID, nil
+}
+
+// resolveStremioID parses a Stremio content ID into a TMDB ID and optional
+// season/episode. Supported forms:
+//
+//	eztmdb:123
+//	eztmdb:123:1:2
+//	tt1234567
+//	tt1234567:1:2
+func resolveStremioID(metaType, id string) (tmdbID, season, episode string, err error) {
+	idParts := strings.Split(id, ":")
+
+	switch {
+	case idParts[0] == "eztmdb":
+		if len(idParts) < 2 || idParts[1] == "" {
+			return "", "", "", fmt.Errorf("invalid ID: %s", id)
+		}
+		tmdbID = idParts[1]
+		idParts = idParts[2:]
+	case reIMDbID.MatchString(idParts[0]):
+		tmdbID, err = resolveIMDbID(metaType, idParts[0])
+		if err != nil {
+			return "", "", "", err
+		}
+		idParts = idParts[1:]
+	default:
+		return "", "", "", fmt.Errorf("unsupported ID: %s", id)
+	}
+
+	if len(idParts) >= 2 {
+		season = idParts[0]
+		episode = idParts[1]
+	}
+	return tmdbID, season, episode, nil
+}
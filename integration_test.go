@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCatalogDiscover(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+
+	var resp struct {
+		Metas []MetaPreview `json:"metas"`
+	}
+	getJSON(t, "/catalog/movie/tmdb_movies_cs.json", &resp)
+
+	if len(resp.Metas) != 2 {
+		t.Fatalf("got %d metas, want 2", len(resp.Metas))
+	}
+
+	inception := resp.Metas[0]
+	if inception.ID != "eztmdb:27205" || inception.Name != "Počátek" {
+		t.Errorf("first meta = %s %q, want eztmdb:27205 \"Počátek\"", inception.ID, inception.Name)
+	}
+	if want := "https://image.tmdb.org/t/p/w500/cs27205.jpg"; inception.Poster != want {
+		t.Errorf("poster = %q, want Czech poster %q", inception.Poster, want)
+	}
+	if want := "https://image.tmdb.org/t/p/w500/logo-en27205.png"; inception.Logo != want {
+		t.Errorf("logo = %q, want %q", inception.Logo, want)
+	}
+	if inception.Runtime != "148 min" || len(inception.Cast) != 3 || len(inception.Director) != 1 {
+		t.Errorf("details not merged: runtime %q, cast %v, director %v", inception.Runtime, inception.Cast, inception.Director)
+	}
+
+	// No detail fixture: falls back to the discover data
+	darkKnight := resp.Metas[1]
+	if want := "https://image.tmdb.org/t/p/w500/default155.jpg"; darkKnight.Poster != want {
+		t.Errorf("poster = %q, want discover poster %q", darkKnight.Poster, want)
+	}
+	if darkKnight.ReleaseInfo != "2008" {
+		t.Errorf("releaseInfo = %q, want 2008", darkKnight.ReleaseInfo)
+	}
+}
+
+func TestCatalogGenrePage(t *testing.T) {
+	f := newFakeUpstreams(t, "search_inception.html")
+	savedIDs := genreIDs
+	genreIDs = map[string]map[string]int{"movie": {"Komedie": 35}}
+	t.Cleanup(func() { genreIDs = savedIDs })
+
+	var resp struct {
+		Metas []MetaPreview `json:"metas"`
+	}
+	getJSON(t, "/catalog/movie/tmdb_movies_cs/genre=Komedie&skip=20.json", &resp)
+
+	if len(resp.Metas) != 2 {
+		t.Fatalf("got %d metas, want 2", len(resp.Metas))
+	}
+	queries := f.tmdbRequested("/3/discover/movie")
+	if len(queries) != 1 || queries[0].Get("with_genres") != "35" || queries[0].Get("page") != "2" {
+		t.Errorf("discover requests = %v, want genre 35, page 2", queries)
+	}
+}
+
+func TestCatalogExtrasWithAmpersand(t *testing.T) {
+	f := newFakeUpstreams(t, "search_inception.html")
+	savedIDs := genreIDs
+	genreIDs = map[string]map[string]int{"tv": {"Sci-Fi & Fantasy": 10765}}
+	t.Cleanup(func() { genreIDs = savedIDs })
+
+	cfg := encodeUserConfig(UserConfig{MinHeight: 720})
+	for _, prefix := range []string{"", "/" + cfg} {
+		var resp struct {
+			Metas []MetaPreview `json:"metas"`
+		}
+		getJSON(t, prefix+"/catalog/movie/tmdb_movies_cs/search=Tom%20%26%20Jerry.json", &resp)
+		getJSON(t, prefix+"/catalog/series/tmdb_series_cs/genre=Sci-Fi%20%26%20Fantasy&skip=20.json", &resp)
+	}
+
+	searches := f.tmdbRequested("/3/search/movie")
+	if len(searches) != 2 {
+		t.Fatalf("got %d TMDB searches, want 2", len(searches))
+	}
+	for _, q := range searches {
+		if q.Get("query") != "Tom & Jerry" {
+			t.Errorf("TMDB search query = %q, want \"Tom & Jerry\"", q.Get("query"))
+		}
+	}
+	discovers := f.tmdbRequested("/3/discover/tv")
+	if len(discovers) != 2 {
+		t.Fatalf("got %d TMDB discover requests, want 2", len(discovers))
+	}
+	for _, q := range discovers {
+		if q.Get("with_genres") != "10765" || q.Get("page") != "2" {
+			t.Errorf("discover query = %v, want genre 10765, page 2", q)
+		}
+	}
+}
+
+func TestMetaMovie(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+
+	var resp struct {
+		Meta *Meta `json:"meta"`
+	}
+	getJSON(t, "/meta/movie/eztmdb:27205.json", &resp)
+
+	m := resp.Meta
+	if m == nil {
+		t.Fatal("meta is null")
+	}
+	if m.Name != "Počátek" || m.ReleaseInfo != "2010" || m.Runtime != "148 min" {
+		t.Errorf("meta = %q %q %q, want Počátek 2010 148 min", m.Name, m.ReleaseInfo, m.Runtime)
+	}
+	if want := "https://image.tmdb.org/t/p/original/backdrop27205.jpg"; m.Background != want {
+		t.Errorf("background = %q, want %q", m.Background, want)
+	}
+	if len(m.Director) != 1 || m.Director[0] != "Christopher Nolan" {
+		t.Errorf("director = %v, want [Christopher Nolan]", m.Director)
+	}
+}
+
+func TestInvalidIMDbID(t *testing.T) {
+	f := newFakeUpstreams(t, "search_breaking_bad.html")
+
+	for _, id := range []string{"ttabc", "tt", "tt0903747x", "tt..%2F..%2Fmovie%2F1", "tt1?api_key=x"} {
+		if _, _, _, err := resolveStremioID("series", id); err == nil || !strings.Contains(err.Error(), "unsupported ID") {
+			t.Errorf("resolveStremioID(%q) err = %v, want unsupported ID", id, err)
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.tmdbRequests) != 0 {
+		t.Errorf("invalid IDs were sent to TMDB: %q", f.tmdbRequests)
+	}
+}
+
+func TestMetaSeriesIMDb(t *testing.T) {
+	newFakeUpstreams(t, "search_breaking_bad.html")
+
+	var resp struct {
+		Meta *Meta `json:"meta"`
+	}
+	getJSON(t, "/meta/series/tt0903747.json", &resp)
+
+	m := resp.Meta
+	if m == nil {
+		t.Fatal("meta is null")
+	}
+	if m.ID != "tt0903747" || m.Name != "Perníkový táta" || m.ReleaseInfo != "2008-2013" {
+		t.Errorf("meta = %s %q %q, want tt0903747 \"Perníkový táta\" 2008-2013", m.ID, m.Name, m.ReleaseInfo)
+	}
+	if want := "https://image.tmdb.org/t/p/w500/sk1396.jpg"; m.Poster != want {
+		t.Errorf("poster = %q, want Slovak poster %q", m.Poster, want)
+	}
+
+	// Specials (season 0) are skipped, episode IDs stay in the IMDb namespace
+	if len(m.Videos) != 3 {
+		t.Fatalf("got %d videos, want 3", len(m.Videos))
+	}
+	for _, v := range m.Videos {
+		if v.Season != 1 || !strings.HasPrefix(v.ID, "tt0903747:1:") {
+			t.Errorf("video %q is S%d, want tt0903747:1:N", v.ID, v.Season)
+		}
+		if v.Episode == 2 && v.Thumbnail != "https://image.tmdb.org/t/p/original/backdrop1396.jpg" {
+			t.Errorf("episode without still: thumbnail = %q, want backdrop", v.Thumbnail)
+		}
+	}
+}
+
+func TestMetaCacheCopies(t *testing.T) {
+	newFakeUpstreams(t, "search_breaking_bad.html")
+	ctx := context.Background()
+
+	first, err := fetchTMDBMeta(ctx, "series", "1396")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Callers (e.g. handleMeta for IMDb IDs) rewrite the returned Meta
+	first.Videos[0].ID = "changed"
+	first.Genres = append(first.Genres[:0], "changed")
+
+	second, err := fetchTMDBMeta(ctx, "series", "1396")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Videos[0].ID == "changed" || (len(second.Genres) > 0 && second.Genres[0] == "changed") {
+		t.Errorf("cached meta was modified through the returned copy: %+v", second)
+	}
+	second.Videos[1].ID = "changed"
+	third, _ := fetchTMDBMeta(ctx, "series", "1396")
+	if third.Videos[1].ID == "changed" {
+		t.Error("cache hits share Videos")
+	}
+}
+
+func TestStreamMovie(t *testing.T) {
+	f := newFakeUpstreams(t, "search_inception.html")
+
+	var resp struct {
+		Streams []Stream `json:"streams"`
+	}
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+
+	if len(resp.Streams) != 3 {
+		t.Fatalf("got %d streams, want 3: %+v", len(resp.Streams), resp.Streams)
+	}
+
+	// Alternative titles are searched once, without variations
+	if !f.requested("/hledej/Počiatok") || f.requested("/hledej/Počiatok 2010") || f.requested("/hledej/Pociatok") {
+		t.Error("alternative title not searched exactly once")
+	}
+
+	// Wrong year and unrelated titles are never opened
+	for _, p := range []string{"/pocatek-1998-cz/c9d0e1f2", "/kozy-vaclav-2010-cz-dabing/a3b4c5d6"} {
+		if f.requested(p) {
+			t.Errorf("filtered result %s was resolved", p)
+		}
+	}
+
+	// CZ dub in the best quality first
+	first := resp.Streams[0]
+	if !strings.HasPrefix(first.URL, "https://cdn.example/a1b2c3d4/1080.mp4") {
+		t.Errorf("first stream = %s, want the dubbed 1080p upload", first.URL)
+	}
+	if !strings.Contains(first.Title, "Počátek (2010) CZ dabing 1080p") {
+		t.Errorf("title %q does not name the upload", first.Title)
+	}
+	h := first.BehaviorHints
+	if h == nil || h.ProxyHeaders == nil {
+		t.Fatalf("missing proxy headers: %+v", h)
+	}
+	if want := f.Prehraj.URL + "/pocatek-2010-cz-dabing-1080p/a1b2c3d4"; h.ProxyHeaders.Request["Referer"] != want {
+		t.Errorf("Referer = %q, want %q", h.ProxyHeaders.Request["Referer"], want)
+	}
+	if h.BingeGroup != "ezstremio-prehraj.to-1080p" || h.VideoSize != 0 {
+		t.Errorf("bingeGroup = %q, videoSize = %d", h.BingeGroup, h.VideoSize)
+	}
+
+	// A second request is served from the stream cache
+	before := f.requestCount()
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+	if len(resp.Streams) != 3 || f.requestCount() != before {
+		t.Errorf("cached request returned %d streams and made %d requests", len(resp.Streams), f.requestCount()-before)
+	}
+
+	// Subtitles of the playing upload
+	var subs struct {
+		Subtitles []Subtitle `json:"subtitles"`
+	}
+	extra := url.Values{"filename": {h.Filename}}.Encode()
+	getJSON(t, "/subtitles/movie/eztmdb:27205/"+extra+".json", &subs)
+	if len(subs.Subtitles) != 1 || subs.Subtitles[0].Lang != "cze" {
+		t.Fatalf("subtitles = %+v, want one Czech track", subs.Subtitles)
+	}
+	if want := f.Prehraj.URL + "/titulky/a1b2c3d4-cs.vtt"; subs.Subtitles[0].URL != want {
+		t.Errorf("subtitle URL = %q, want %q", subs.Subtitles[0].URL, want)
+	}
+}
+
+func TestStreamProxy(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+	Config.ProxyStreams = true
+	Config.ProxySecret = nil
+	Config.ProxyTokenTTL = time.Hour
+	initProxySecret()
+
+	var resp struct {
+		Streams []Stream `json:"streams"`
+	}
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+	if len(resp.Streams) == 0 {
+		t.Fatal("no streams")
+	}
+
+	u, err := url.Parse(resp.Streams[0].URL)
+	if err != nil || !strings.HasPrefix(u.Path, "/proxy/") {
+		t.Fatalf("stream URL %q is not proxied", resp.Streams[0].URL)
+	}
+	token, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/proxy/"), "/")
+	pt, err := decodeProxyToken(token)
+	if err != nil || !strings.HasPrefix(pt.URL, "https://cdn.example/a1b2c3d4/1080.mp4") || pt.Headers["Referer"] == "" {
+		t.Errorf("token = %+v, %v", pt, err)
+	}
+
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/x"+token+"/video.mp4", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("tampered token: status %d, want 403", rec.Code)
+	}
+}
+
+func TestSubtitlesWithoutCachedStreams(t *testing.T) {
+	f := newFakeUpstreams(t, "search_inception.html")
+
+	// No stream request before (cache expired, restart): nothing is scraped
+	var subs struct {
+		Subtitles []Subtitle `json:"subtitles"`
+	}
+	getJSON(t, "/subtitles/movie/eztmdb:27205.json", &subs)
+
+	if len(subs.Subtitles) != 0 || f.requestCount() != 0 {
+		t.Errorf("got %d subtitles and %d requests, want none", len(subs.Subtitles), f.requestCount())
+	}
+}
+
+func TestStreamEmptyResultCached(t *testing.T) {
+	// Only Breaking Bad uploads: nothing matches Inception
+	f := newFakeUpstreams(t, "search_breaking_bad.html")
+
+	var resp struct {
+		Streams []Stream `json:"streams"`
+	}
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+	if len(resp.Streams) != 0 {
+		t.Fatalf("got %d streams, want none", len(resp.Streams))
+	}
+
+	before := f.requestCount()
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+	if f.requestCount() != before {
+		t.Errorf("repeated request made %d requests, want the empty result cached", f.requestCount()-before)
+	}
+	if ttl := streamCacheTTL(nil); ttl != emptyStreamCacheTTL {
+		t.Errorf("streamCacheTTL(nil) = %s, want %s", ttl, emptyStreamCacheTTL)
+	}
+}
+
+func TestStreamSeriesEpisode(t *testing.T) {
+	f := newFakeUpstreams(t, "search_breaking_bad.html")
+
+	var resp struct {
+		Streams []Stream `json:"streams"`
+	}
+	getJSON(t, "/stream/series/tt0903747:1:2.json", &resp)
+
+	got := make(map[string]bool)
+	for _, s := range resp.Streams {
+		got[s.URL] = true
+	}
+	for _, want := range []string{
+		"https://cdn.example/b1b2b3b4/720.mp4?expires=4102444800&token=jkl",
+		"https://cdn.example/b9c0c1c2/1080.mp4?expires=4102444800&token=mno",
+		"https://cdn.example/b9c0c1c2/480.mp4?expires=4102444800&token=pqr",
+	} {
+		if !got[want] {
+			t.Errorf("missing stream %s", want)
+		}
+	}
+	if len(resp.Streams) != 3 {
+		t.Errorf("got %d streams, want 3", len(resp.Streams))
+	}
+	if f.requested("/breaking-bad-s01e03-cz-titulky/b5b6b7b8") {
+		t.Error("other episode was resolved")
+	}
+}
+
+func TestStreamUserConfig(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+
+	cfg := encodeUserConfig(UserConfig{Audio: []string{langCS}, MinHeight: 1080})
+	var resp struct {
+		Streams []Stream `json:"streams"`
+	}
+	getJSON(t, "/"+cfg+"/stream/movie/eztmdb:27205.json", &resp)
+
+	if len(resp.Streams) != 1 || !strings.HasPrefix(resp.Streams[0].URL, "https://cdn.example/a1b2c3d4/1080.mp4") {
+		t.Errorf("streams = %+v, want only the dubbed 1080p upload", resp.Streams)
+	}
+}
+
+func TestScraperHealth(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+	Config.CanaryQuery = "Počátek"
+
+	var report canaryReport
+	getJSON(t, "/health/scraper?refresh=true", &report)
+
+	if !report.OK || report.FailedStage != "" {
+		t.Fatalf("report = %+v, want OK", report)
+	}
+	want := []canaryStage{
+		{Name: stageLogin, Status: "skipped", Detail: "no credentials configured"},
+		{Name: stageSearch, Status: "ok"},
+		{Name: stageSearchParse, Status: "ok", Detail: "4 results"},
+		{Name: stageSources, Status: "ok", Detail: "2 streams"},
+		{Name: stageResolution, Status: "ok", Detail: "1080p"},
+	}
+	if len(report.Stages) != len(want) {
+		t.Fatalf("stages = %+v, want %+v", report.Stages, want)
+	}
+	for i, s := range report.Stages {
+		if s != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, s, want[i])
+		}
+	}
+}
+
+func TestScraperHealthRefreshLimit(t *testing.T) {
+	f := newFakeUpstreams(t, "search_inception.html")
+	Config.CanaryQuery = "Počátek"
+
+	var first, second canaryReport
+	getJSON(t, "/health/scraper?refresh=true", &first)
+	requests := f.requestCount()
+	getJSON(t, "/health/scraper?refresh=true", &second)
+
+	if f.requestCount() != requests || !second.CheckedAt.Equal(first.CheckedAt) {
+		t.Errorf("refresh within %s ran the canary again (%d requests)", canaryMinRefreshAge, f.requestCount()-requests)
+	}
+
+	// Older reports are refreshed
+	scraperHealth.report.CheckedAt = time.Now().Add(-canaryMinRefreshAge)
+	getJSON(t, "/health/scraper?refresh=true", &second)
+	if f.requestCount() == requests {
+		t.Error("stale report was not refreshed")
+	}
+}
+
+func TestScraperHealthSearchFailure(t *testing.T) {
+	newFakeUpstreams(t, "missing.html")
+
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/scraper?refresh=true", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+
+	var report canaryReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.OK || report.FailedStage != stageSearch {
+		t.Errorf("failedStage = %q, want %q", report.FailedStage, stageSearch)
+	}
+	if last := report.Stages[len(report.Stages)-1]; last.Name != stageResolution || last.Status != "skipped" {
+		t.Errorf("later stages not skipped: %+v", report.Stages)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+
+	var resp struct {
+		Streams []Stream `json:"streams"`
+	}
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+	getJSON(t, "/stream/movie/eztmdb:27205.json", &resp)
+
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`ezstremio_http_requests_total{code="200",resource="stream"}`,
+		`ezstremio_http_request_duration_seconds_bucket{resource="stream"`,
+		`ezstremio_cache_requests_total{cache="stream",result="hit"}`,
+		`ezstremio_cache_requests_total{cache="stream",result="miss"}`,
+		`ezstremio_provider_results_count{provider="Prehraj.to",stage="extracted"}`,
+		`ezstremio_upstream_requests_total{code="200",upstream="tmdb"}`,
+		`ezstremio_prehraj_logged_in 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+}
+
+func TestRequestIDLogging(t *testing.T) {
+	newFakeUpstreams(t, "search_inception.html")
+
+	var buf bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})}))
+	t.Cleanup(func() { slog.SetDefault(saved) })
+
+	req := httptest.NewRequest(http.MethodGet, "/stream/movie/eztmdb:27205.json", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("X-Request-ID = %q, want req-42", got)
+	}
+
+	// Every line logged while handling the request carries its ID
+	seen := make(map[string]bool)
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]interface{}
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := line["msg"].(string)
+		if line["request_id"] != "req-42" {
+			t.Errorf("%q logged without the request ID: %v", msg, line)
+		}
+		seen[msg] = true
+	}
+	for _, want := range []string{"Handling Stream request", "Fetched TMDB meta", "Searching Prehraj.to", "Extracted Prehraj.to streams"} {
+		if !seen[want] {
+			t.Errorf("no %q log line", want)
+		}
+	}
+
+	// Invalid incoming IDs are replaced
+	req = httptest.NewRequest(http.MethodGet, "/manifest.json", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	rec = httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); !validRequestID(got) || got == "bad id\n" {
+		t.Errorf("X-Request-ID = %q, want a generated ID", got)
+	}
+}
+
+func TestHTTPLoginKeepsCookieAttributes(t *testing.T) {
+	expires := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			if r.Form.Get("_token_") != "t" || r.Form.Get("email") != "me@example.com" {
+				http.Error(w, "bad form", http.StatusBadRequest)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "logged", Path: "/", Expires: expires, HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "permanent", Value: "1", Path: "/", MaxAge: 3600})
+			w.Write([]byte(`<html><body><a href="/profil">Profil</a></body></html>`))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "anon", Path: "/"})
+		w.Write([]byte(`<html><body><form id="frm-homepageLoginForm-loginForm" action="/?do=login" method="post">
+<input type="hidden" name="_token_" value="t"><input name="email"><input type="password" name="password">
+<button type="submit" name="login">Přihlásit</button></form></body></html>`))
+	}))
+	t.Cleanup(srv.Close)
+	savedBase := prehrajBaseURL
+	prehrajBaseURL = srv.URL
+	t.Cleanup(func() { prehrajBaseURL = savedBase })
+
+	cookies, err := httpLogin("me@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := saveSessionCookies(path, cookies); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := loadSessionCookies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]*http.Cookie)
+	for _, c := range restored {
+		got[c.Name] = c
+	}
+	if c := got["PHPSESSID"]; c == nil || c.Value != "logged" || !c.Expires.Equal(expires) || !c.HttpOnly || c.Path != "/" {
+		t.Errorf("PHPSESSID = %+v, want the logged-in cookie expiring %s", c, expires)
+	}
+	if c := got["permanent"]; c == nil || time.Until(c.Expires) < 59*time.Minute || time.Until(c.Expires) > time.Hour {
+		t.Errorf("permanent = %+v, want Max-Age converted to an expiry in 1h", c)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Language codes used for detected audio/subtitle languages
+const (
+	langCS = "cs"
+	langSK = "sk"
+	langEN = "en"
+)
+
+// Tokens in release titles that name a language
+var langTokens = map[string][]string{
+	"cz": {langCS}, "cs": {langCS}, "cze": {langCS}, "cesky": {langCS}, "czech": {langCS}, "cestina": {langCS},
+	"sk": {langSK}, "svk": {langSK}, "slovensky": {langSK}, "slovak": {langSK}, "slovencina": {langSK},
+	"en": {langEN}, "eng": {langEN}, "english": {langEN}, "anglicky": {langEN},
+	"czsk": {langCS, langSK}, "czsvk": {langCS, langSK},
+}
+
+// Tokens marking a dub (audio) or subtitles
+var (
+	dubTokens      = map[string]bool{"dabing": true, "dab": true, "dabovane": true, "dub": true, "dubbed": true}
+	subtitleTokens = map[string]bool{"titulky": true, "tit": true, "titl": true, "sub": true, "subs": true, "subtitles": true, "forced": true}
+)
+
+// Glued forms like "CZdab" or "CZtit"
+var gluedLangTokens = map[string]struct {
+	lang     string
+	subtitle bool
+}{
+	"czdab": {langCS, false}, "czdabing": {langCS, false}, "skdab": {langSK, false}, "skdabing": {langSK, false},
+	"cztit": {langCS, true}, "cztitulky": {langCS, true}, "sktit": {langSK, true}, "sktitulky": {langSK, true},
+	"czsub": {langCS, true}, "sksub": {langSK, true}, "ensub": {langEN, true}, "entit": {langEN, true},
+}
+
+// detectLanguages parses a release title for audio and subtitle language
+// markers such as "CZ dabing", "SK", "CZ titulky" or "EN".
+//
+// A language followed or preceded by a subtitle marker ("CZ titulky",
+// "tit. CZ") counts as subtitles; any other language mention counts as audio.
+// A bare "dabing" means Czech audio and bare "titulky" Czech subtitles.
+func detectLanguages(title string) (audio, subtitles []string) {
+	tokens := strings.FieldsFunc(normalizeStringForFilter(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	addUnique := func(list []string, langs ...string) []string {
+		for _, l := range langs {
+			found := false
+			for _, existing := range list {
+				if existing == l {
+					found = true
+					break
+				}
+			}
+			if !found {
+				list = append(list, l)
+			}
+		}
+		return list
+	}
+
+	sawDub, sawSubs := false, false
+	for i, t := range tokens {
+		if g, ok := gluedLangTokens[t]; ok {
+			if g.subtitle {
+				subtitles = addUnique(subtitles, g.lang)
+			} else {
+				audio = addUnique(audio, g.lang)
+			}
+			continue
+		}
+		if dubTokens[t] {
+			sawDub = true
+			continue
+		}
+		if subtitleTokens[t] {
+			sawSubs = true
+			continue
+		}
+
+		langs, ok := langTokens[t]
+		if !ok {
+			continue
+		}
+		isSub := (i+1 < len(tokens) && subtitleTokens[tokens[i+1]]) ||
+			(i > 0 && subtitleTokens[tokens[i-1]])
+		if isSub {
+			subtitles = addUnique(subtitles, langs...)
+		} else {
+			audio = addUnique(audio, langs...)
+		}
+	}
+
+	if sawDub && len(audio) == 0 {
+		audio = []string{langCS}
+	}
+	if sawSubs && len(subtitles) == 0 {
+		subtitles = []string{langCS}
+	}
+	return audio, subtitles
+}
+
+// parseLanguageList parses a comma separated list like "cz,sk" into language
+// codes, accepting the same spellings as release titles.
+func parseLanguageList(s string) []string {
+	var langs []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if codes, ok := langTokens[part]; ok {
+			langs = append(langs, codes...)
+		}
+	}
+	return langs
+}
+
+// hasLocalAudio reports whether the languages include a Czech or Slovak dub.
+func hasLocalAudio(audio []string) bool {
+	for _, l := range audio {
+		if l == langCS || l == langSK {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAudioFilter reports whether any detected audio language is allowed.
+// An empty filter allows everything.
+func matchesAudioFilter(audio, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range audio {
+		for _, l := range allowed {
+			if a == l {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatLanguages renders detected languages for the stream description,
+// e.g. "🗣️ CZ, SK • 💬 EN".
+func formatLanguages(audio, subtitles []string) string {
+	upper := func(langs []string) string {
+		out := make([]string, len(langs))
+		for i, l := range langs {
+			if l == langCS {
+				l = "cz" // What uploaders (and users) call it
+			}
+			out[i] = strings.ToUpper(l)
+		}
+		return strings.Join(out, ", ")
+	}
+
+	var parts []string
+	if len(audio) > 0 {
+		parts = append(parts, "🗣️ "+upper(audio))
+	}
+	if len(subtitles) > 0 {
+		parts = append(parts, "💬 "+upper(subtitles))
+	}
+	return strings.Join(parts, " • ")
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectLanguages(t *testing.T) {
+	tests := []struct {
+		title     string
+		audio     []string
+		subtitles []string
+	}{
+		{"Počátek CZ dabing 1080p", []string{"cs"}, nil},
+		{"Počátek SK", []string{"sk"}, nil},
+		{"Počátek CZSK", []string{"cs", "sk"}, nil},
+		{"Počátek CZ SK titulky", []string{"cs"}, []string{"sk"}},
+		{"Inception EN CZ tit", []string{"en"}, []string{"cs"}},
+		{"Počátek CZdab ENsub", []string{"cs"}, []string{"en"}},
+		{"Počátek CZ dabing, EN", []string{"cs", "en"}, nil},
+		{"Počátek dabing", []string{"cs"}, nil},
+		{"Inception titulky", nil, []string{"cs"}},
+		{"Inception 2010 1080p", nil, nil},
+	}
+	for _, tt := range tests {
+		audio, subtitles := detectLanguages(tt.title)
+		if !slices.Equal(audio, tt.audio) || !slices.Equal(subtitles, tt.subtitles) {
+			t.Errorf("detectLanguages(%q) = %q, %q, want %q, %q", tt.title, audio, subtitles, tt.audio, tt.subtitles)
+		}
+	}
+}
@@ -2,23 +2,44 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
-	"regexp"
-	"sort"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Config holds the application configuration
 var Config struct {
-	TMDBApiKey string
+	TMDBApiKey          string
+	MetaCacheTTL        time.Duration // Series still airing and unreleased movies
+	MetaCacheTTLEnded   time.Duration // Released movies and ended/canceled series
+	MetaCacheFile       string        // Optional path for persisting the meta cache
+	AvailabilityFile    string        // Optional path for persisting the dub availability index
+	AvailabilityEvery   time.Duration // How often the availability worker crawls (0 disables it)
+	AvailabilityPages   int           // Discover pages crawled per type
+	StreamCacheTTL      time.Duration // Upper bound for caching extracted stream lists
+	AudioFilter         []string      // Default audio languages for users without config (empty = all)
+	ScoreWeights        ScoreWeights  // Stream ranking weights
+	SimilarityThreshold float64       // Minimum title similarity (0..1) for search results
+	ProxyStreams        bool          // Emit /proxy/ URLs instead of direct CDN links
+	ProxySecret         []byte        // HMAC key for proxy tokens (random when unset)
+	ProxyTokenTTL       time.Duration // How long a proxied URL stays valid
+	PublicURL           string        // External base URL used in proxied links (optional)
+	SubtitlesDir        string        // Optional directory with local subtitle files
+	CanaryQuery         string        // Search used by the scraper health check
+	CanaryEvery         time.Duration // How often the scraper health check runs (0 disables it)
+	LogLevel            string        // debug, info, warn or error
+	LogFormat           string        // text or json
 }
 
 // Global cache for localized poster paths to reduce API calls
@@ -27,26 +48,41 @@ var posterCache = struct {
 	m map[int]string
 }{m: make(map[int]string)}
 
-// HTTP client with timeout
+// Upstream base URLs, overridable so tests can point them at local fakes
+var (
+	tmdbBaseURL    = "https://api.themoviedb.org/3"
+	prehrajBaseURL = "https://prehraj.to"
+)
+
+// HTTP client with timeout (TMDB API)
 var httpClient = &http.Client{
-	Timeout: 10 * time.Second,
+	Timeout:   10 * time.Second,
+	Transport: newUpstreamTransport("tmdb", nil),
 }
 
 // Manifest defines the metadata for the Stremio addon.
 type Manifest struct {
-	ID          string    `json:"id"`
-	Version     string    `json:"version"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Resources   []string  `json:"resources"`
-	Types       []string  `json:"types"`
-	Catalogs    []Catalog `json:"catalogs"`
-	IdPrefixes  []string  `json:"idPrefixes"`
+	ID            string                `json:"id"`
+	Version       string                `json:"version"`
+	Name          string                `json:"name"`
+	Description   string                `json:"description"`
+	Resources     []string              `json:"resources"`
+	Types         []string              `json:"types"`
+	Catalogs      []Catalog             `json:"catalogs"`
+	IdPrefixes    []string              `json:"idPrefixes"`
+	BehaviorHints ManifestBehaviorHints `json:"behaviorHints"`
+}
+
+// ManifestBehaviorHints tells Stremio how to treat the addon.
+type ManifestBehaviorHints struct {
+	Configurable          bool `json:"configurable"`
+	ConfigurationRequired bool `json:"configurationRequired"`
 }
 
 type CatalogExtra struct {
-	Name       string `json:"name"`
-	IsRequired bool   `json:"isRequired,omitempty"`
+	Name       string   `json:"name"`
+	IsRequired bool     `json:"isRequired,omitempty"`
+	Options    []string `json:"options,omitempty"`
 }
 
 // Catalog defines a content catalog.
@@ -83,6 +119,10 @@ type TMDBGenreResponse struct {
 
 var genreMap = make(map[int]string)
 
+// Genre names (in TMDB order) and name -> ID lookup per TMDB type ("movie", "tv")
+var genreNames = make(map[string][]string)
+var genreIDs = make(map[string]map[string]int)
+
 // TMDBResponse structure for decoding TMDB API responses
 type TMDBResponse struct {
 	Results []struct {
@@ -149,6 +189,32 @@ type Meta struct {
 	Videos       []MetaVideo `json:"videos,omitempty"`
 	OriginalName string      `json:"-"` // Internal use for search
 	Year         string      `json:"-"` // Internal use for search
+	Ended        bool        `json:"-"` // Internal use for caching (released movie or finished series)
+	AltNames     []string    `json:"-"` // Internal use for search (CZ/SK alternative titles and translations)
+}
+
+// clone returns a copy of m that shares no slices with it.
+func (m *Meta) clone() *Meta {
+	c := *m
+	c.Genres = slices.Clone(m.Genres)
+	c.Cast = slices.Clone(m.Cast)
+	c.Director = slices.Clone(m.Director)
+	c.Videos = slices.Clone(m.Videos)
+	c.AltNames = slices.Clone(m.AltNames)
+	return &c
+}
+
+// SearchNames returns the localized, original and alternative names, deduplicated.
+func (m *Meta) SearchNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, n := range append([]string{m.Name, m.OriginalName}, m.AltNames...) {
+		if n != "" && !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names
 }
 
 // TMDBDetail structure for decoding TMDB API detail responses
@@ -165,6 +231,7 @@ type TMDBDetail struct {
 	ReleaseDate    string  `json:"release_date"`     // movie
 	FirstAirDate   string  `json:"first_air_date"`   // tv
 	LastAirDate    string  `json:"last_air_date"`    // tv
+	Status         string  `json:"status"`           // e.g. "Released", "Ended", "Returning Series"
 	Runtime        int     `json:"runtime"`          // movie
 	EpisodeRunTime []int   `json:"episode_run_time"` // tv
 	Genres         []struct {
@@ -188,6 +255,69 @@ type TMDBDetail struct {
 		Posters []TMDBImage `json:"posters"`
 		Logos   []TMDBImage `json:"logos"`
 	} `json:"images"`
+	AlternativeTitles struct {
+		Titles  []TMDBAlternativeTitle `json:"titles"`  // movie
+		Results []TMDBAlternativeTitle `json:"results"` // tv
+	} `json:"alternative_titles"`
+	Translations struct {
+		Translations []struct {
+			ISO3166_1 string `json:"iso_3166_1"`
+			ISO639_1  string `json:"iso_639_1"`
+			Data      struct {
+				Title string `json:"title"` // movie
+				Name  string `json:"name"`  // tv
+			} `json:"data"`
+		} `json:"translations"`
+	} `json:"translations"`
+}
+
+type TMDBAlternativeTitle struct {
+	ISO3166_1 string `json:"iso_3166_1"`
+	Title     string `json:"title"`
+	Type      string `json:"type"`
+}
+
+// Max number of alternative names kept for relevance checks, and how many of
+// them are searched for (one exact query each)
+const (
+	maxAltNames   = 4
+	maxAltQueries = 2
+)
+
+// altNames collects CZ/SK alternative titles and translations that differ
+// from the primary names, Slovak translation first.
+func (d *TMDBDetail) altNames(primary ...string) []string {
+	seen := make(map[string]bool)
+	for _, p := range primary {
+		seen[normalizeStringForFilter(p)] = true
+	}
+
+	var names []string
+	add := func(name string) {
+		norm := normalizeStringForFilter(name)
+		if norm == "" || seen[norm] || len(names) >= maxAltNames {
+			return
+		}
+		seen[norm] = true
+		names = append(names, name)
+	}
+
+	for _, lang := range []string{"sk", "cs"} {
+		for _, t := range d.Translations.Translations {
+			if t.ISO639_1 == lang {
+				add(t.Data.Title)
+				add(t.Data.Name)
+			}
+		}
+	}
+	for _, country := range []string{"SK", "CZ"} {
+		for _, t := range append(d.AlternativeTitles.Titles, d.AlternativeTitles.Results...) {
+			if t.ISO3166_1 == country {
+				add(t.Title)
+			}
+		}
+	}
+	return names
 }
 
 var manifest = Manifest{
@@ -195,7 +325,7 @@ var manifest = Manifest{
 	Version:     "0.1.1",
 	Name:        "ezStremio",
 	Description: "Czech/Slovak dubbed films and TV shows",
-	Resources:   []string{"catalog", "stream", "meta"},
+	Resources:   []string{"catalog", "stream", "meta", "subtitles"},
 	Types:       []string{"movie", "series"},
 	Catalogs: []Catalog{
 		{
@@ -204,6 +334,7 @@ var manifest = Manifest{
 			Name: "CZ/SK Movies (TMDB)",
 			Extra: []CatalogExtra{
 				{Name: "search"},
+				{Name: "genre"},
 				{Name: "skip"},
 			},
 		},
@@ -213,11 +344,63 @@ var manifest = Manifest{
 			Name: "CZ/SK Series (TMDB)",
 			Extra: []CatalogExtra{
 				{Name: "search"},
+				{Name: "genre"},
 				{Name: "skip"},
 			},
 		},
+		{
+			Type:  "movie",
+			ID:    availableCatalogID,
+			Name:  "CZ/SK Dubbed Movies",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "series",
+			ID:    availableCatalogID,
+			Name:  "CZ/SK Dubbed Series",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "movie",
+			ID:    "tmdb_trending_week",
+			Name:  "CZ/SK Trending Movies (TMDB)",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "series",
+			ID:    "tmdb_trending_week",
+			Name:  "CZ/SK Trending Series (TMDB)",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "movie",
+			ID:    "tmdb_top_rated",
+			Name:  "CZ/SK Top Rated Movies (TMDB)",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "series",
+			ID:    "tmdb_top_rated",
+			Name:  "CZ/SK Top Rated Series (TMDB)",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "movie",
+			ID:    "tmdb_now_playing",
+			Name:  "CZ/SK In Cinemas (TMDB)",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+		{
+			Type:  "series",
+			ID:    "tmdb_on_the_air",
+			Name:  "CZ/SK On The Air (TMDB)",
+			Extra: []CatalogExtra{{Name: "skip"}},
+		},
+	},
+	IdPrefixes: []string{"eztmdb:", "tt"},
+	BehaviorHints: ManifestBehaviorHints{
+		Configurable: true,
 	},
-	IdPrefixes: []string{"eztmdb:"},
 }
 
 func loadEnv() {
@@ -243,20 +426,64 @@ func loadEnv() {
 	}
 }
 
+// envDuration reads a duration (e.g. "6h") from the environment, falling back to def.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("Invalid duration, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
 func main() {
 	loadEnv()
+	Config.LogLevel = os.Getenv("LOG_LEVEL")
+	Config.LogFormat = os.Getenv("LOG_FORMAT")
+	initLogging()
 	InitBrowser()
 	Config.TMDBApiKey = os.Getenv("TMDB_API_KEY")
+	Config.MetaCacheTTL = envDuration("META_CACHE_TTL", 6*time.Hour)
+	Config.MetaCacheTTLEnded = envDuration("META_CACHE_TTL_ENDED", 7*24*time.Hour)
+	Config.MetaCacheFile = os.Getenv("META_CACHE_FILE")
+	Config.StreamCacheTTL = envDuration("STREAM_CACHE_TTL", 20*time.Minute)
+	Config.AudioFilter = parseLanguageList(os.Getenv("AUDIO_FILTER"))
+	Config.ScoreWeights = parseScoreWeights(os.Getenv("SCORE_WEIGHTS"))
+	Config.SimilarityThreshold = 0.5
+	if v, err := strconv.ParseFloat(os.Getenv("SIMILARITY_THRESHOLD"), 64); err == nil {
+		Config.SimilarityThreshold = v
+	}
+	Config.ProxyStreams = os.Getenv("PROXY_STREAMS") == "true"
+	Config.ProxySecret = []byte(os.Getenv("PROXY_SECRET"))
+	initProxySecret()
+	Config.ProxyTokenTTL = envDuration("PROXY_TOKEN_TTL", 6*time.Hour)
+	Config.PublicURL = os.Getenv("PUBLIC_URL")
+	Config.SubtitlesDir = os.Getenv("SUBTITLES_DIR")
+	Config.CanaryQuery = os.Getenv("SCRAPER_CANARY_QUERY")
+	if Config.CanaryQuery == "" {
+		Config.CanaryQuery = "Shrek"
+	}
+	Config.CanaryEvery = envDuration("SCRAPER_CANARY_INTERVAL", time.Hour)
+	Config.AvailabilityFile = os.Getenv("AVAILABILITY_FILE")
+	Config.AvailabilityEvery = envDuration("AVAILABILITY_INTERVAL", 12*time.Hour)
+	Config.AvailabilityPages = 5
+	if v, err := strconv.Atoi(os.Getenv("AVAILABILITY_PAGES")); err == nil && v > 0 {
+		Config.AvailabilityPages = v
+	}
 	if Config.TMDBApiKey == "" {
-		log.Println("Warning: TMDB_API_KEY environment variable not set. Catalog will fail.")
+		slog.Warn("TMDB_API_KEY environment variable not set. Catalog will fail.")
 	} else {
 		loadGenres()
 	}
-
-	http.HandleFunc("/manifest.json", handleManifest)
-	http.HandleFunc("/catalog/", handleCatalog)
-	http.HandleFunc("/meta/", handleMeta)
-	http.HandleFunc("/stream/", handleStream)
+	initMetaCache()
+	if Config.TMDBApiKey != "" {
+		startAvailabilityWorker()
+	}
+	startScraperCanary()
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -264,21 +491,65 @@ func main() {
 	}
 
 	addr := ":" + port
-	log.Printf("Addon active on http://localhost%s/manifest.json", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatal(err)
+	slog.Info("Addon active", "manifest", "http://localhost"+addr+"/manifest.json")
+	if err := http.ListenAndServe(addr, newRouter()); err != nil {
+		slog.Error("Server stopped", "err", err)
+		os.Exit(1)
 	}
 }
 
+// newRouter returns the addon's HTTP handler with all routes registered.
+func newRouter() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest.json", handleManifest)
+	mux.HandleFunc("/catalog/", handleCatalog)
+	mux.HandleFunc("/meta/", handleMeta)
+	mux.HandleFunc("/stream/", handleStream)
+	mux.HandleFunc("/configure", handleConfigure)
+	mux.HandleFunc("/proxy/", handleProxy)
+	mux.HandleFunc("/subtitles/", handleSubtitles)
+	mux.HandleFunc("/subtitle-files/", handleSubtitleFile)
+	mux.HandleFunc("/health/scraper", handleScraperHealth)
+	mux.Handle("/metrics", promhttp.Handler())
+	return withRequestID(withUserConfig(instrumentHandler(mux)))
+}
+
 func handleManifest(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling Manifest request")
+	slog.InfoContext(r.Context(), "Handling Manifest request")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(manifest)
+	json.NewEncoder(w).Encode(manifestWithGenres())
+}
+
+// manifestWithGenres returns a copy of the manifest with the genre options
+// (loaded from TMDB at startup) filled in.
+func manifestWithGenres() Manifest {
+	m := manifest
+	m.Catalogs = make([]Catalog, len(manifest.Catalogs))
+	for i, c := range manifest.Catalogs {
+		tmdbType := "movie"
+		if c.Type == "series" {
+			tmdbType = "tv"
+		}
+		extras := make([]CatalogExtra, 0, len(c.Extra))
+		for _, e := range c.Extra {
+			if e.Name == "genre" {
+				if len(genreNames[tmdbType]) == 0 {
+					continue // Genres not loaded, don't advertise an empty filter
+				}
+				e.Options = genreNames[tmdbType]
+			}
+			extras = append(extras, e)
+		}
+		c.Extra = extras
+		m.Catalogs[i] = c
+	}
+	return m
 }
 
 func handleCatalog(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Catalog Request: %s", r.URL.Path)
+	ctx := r.Context()
+	slog.InfoContext(ctx, "Handling Catalog request", "path", r.URL.Path)
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 4 {
 		http.NotFound(w, r)
@@ -292,34 +563,46 @@ func handleCatalog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	page := 1
+	skip := 0
 	query := ""
-
-	if len(parts) > 4 {
-		for _, part := range parts[4:] {
-			if strings.HasSuffix(part, ".json") {
-				part = strings.TrimSuffix(part, ".json")
-			}
-			if strings.HasPrefix(part, "skip=") {
-				if skip, err := strconv.Atoi(strings.TrimPrefix(part, "skip=")); err == nil {
-					page = (skip / 20) + 1
-				}
-			} else if strings.HasPrefix(part, "search=") {
-				query = strings.TrimPrefix(part, "search=")
-				log.Printf("Search query detected: %s", query)
-			}
+	genre := ""
+
+	// Multiple extras share one segment: genre=Komedie&skip=20.json. Parse
+	// the escaped path, values may contain "&" ("Sci-Fi %26 Fantasy").
+	if escaped := strings.Split(strings.TrimSuffix(r.URL.EscapedPath(), ".json"), "/"); len(escaped) > 4 {
+		extra, _ := url.ParseQuery(escaped[4])
+		if v, err := strconv.Atoi(extra.Get("skip")); err == nil {
+			skip = v
+			page = (skip / 20) + 1
+		}
+		if query = extra.Get("search"); query != "" {
+			slog.DebugContext(ctx, "Search query detected", "query", query)
+		}
+		if genre = extra.Get("genre"); genre != "" {
+			slog.DebugContext(ctx, "Genre filter detected", "genre", genre)
 		}
 	}
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
+	userCfg := userConfigFrom(r)
+
+	if catID == availableCatalogID {
+		json.NewEncoder(w).Encode(map[string]interface{}{"metas": availableItems(catType, skip)})
+		return
+	}
+
 	if strings.HasPrefix(catID, "tmdb_") {
-		items, err := fetchTMDBItems(catType, page, query)
+		items, err := fetchTMDBItems(ctx, catType, catID, page, query, genre)
 		if err != nil {
-			log.Printf("Error fetching TMDB items: %v", err)
+			slog.ErrorContext(ctx, "Error fetching TMDB items", "err", err)
 			json.NewEncoder(w).Encode(map[string]interface{}{"metas": []interface{}{}})
 			return
 		}
+		if userCfg.wantsDubOnly() {
+			items = filterUnavailable(catType, items)
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"metas": items})
 		return
 	}
@@ -340,19 +623,34 @@ func handleMeta(w http.ResponseWriter, r *http.Request) {
 		metaID = strings.TrimSuffix(metaID, ".json")
 	}
 
-	log.Printf("Handling Meta request for Type: %s, ID: %s", metaType, metaID)
+	ctx := r.Context()
+	slog.InfoContext(ctx, "Handling Meta request", "type", metaType, "id", metaID)
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
-	if strings.HasPrefix(metaID, "eztmdb:") {
-		tmdbID := strings.TrimPrefix(metaID, "eztmdb:")
-		meta, err := fetchTMDBMeta(metaType, tmdbID)
+	if strings.HasPrefix(metaID, "eztmdb:") || strings.HasPrefix(metaID, "tt") {
+		tmdbID, _, _, err := resolveStremioID(metaType, metaID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to resolve meta ID", "id", metaID, "err", err)
+			json.NewEncoder(w).Encode(map[string]interface{}{"meta": nil})
+			return
+		}
+		meta, err := fetchTMDBMeta(ctx, metaType, tmdbID)
 		if err != nil {
-			log.Printf("Error fetching TMDB meta: %v", err)
 			json.NewEncoder(w).Encode(map[string]interface{}{"meta": nil})
 			return
 		}
+		if strings.HasPrefix(metaID, "tt") {
+			// Keep the IMDb namespace so episodes resolve back through us and Cinemeta
+			meta.ID = metaID
+			videos := make([]MetaVideo, len(meta.Videos))
+			for i, v := range meta.Videos {
+				v.ID = fmt.Sprintf("%s:%d:%d", metaID, v.Season, v.Episode)
+				videos[i] = v
+			}
+			meta.Videos = videos
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"meta": meta})
 		return
 	}
@@ -360,7 +658,9 @@ func handleMeta(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"meta": nil})
 }
 
-func fetchTMDBMeta(metaType, tmdbID string) (*Meta, error) {
+// loadTMDBMeta fetches full metadata (including all episodes) from TMDB.
+// Use fetchTMDBMeta, which goes through metaCache.
+func loadTMDBMeta(metaType, tmdbID string) (*Meta, error) {
 	if Config.TMDBApiKey == "" {
 		return nil, fmt.Errorf("TMDB API Key missing")
 	}
@@ -371,7 +671,7 @@ func fetchTMDBMeta(metaType, tmdbID string) (*Meta, error) {
 	}
 
 	// Fetch Details with credits and images
-	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%s?api_key=%s&language=cs-CZ&append_to_response=credits,images&include_image_language=cs,sk,en,null", tmdbType, tmdbID, Config.TMDBApiKey)
+	url := fmt.Sprintf("%s/%s/%s?api_key=%s&language=cs-CZ&append_to_response=credits,images,alternative_titles,translations&include_image_language=cs,sk,en,null", tmdbBaseURL, tmdbType, tmdbID, Config.TMDBApiKey)
 
 	resp, err := httpClient.Get(url)
 	if err != nil {
@@ -546,7 +846,7 @@ func fetchTMDBMeta(metaType, tmdbID string) (*Meta, error) {
 			go func(seasonNum int) {
 				defer wgV.Done()
 
-				sUrl := fmt.Sprintf("https://api.themoviedb.org/3/tv/%s/season/%d?api_key=%s&language=cs-CZ", tmdbID, seasonNum, Config.TMDBApiKey)
+				sUrl := fmt.Sprintf("%s/tv/%s/season/%d?api_key=%s&language=cs-CZ", tmdbBaseURL, tmdbID, seasonNum, Config.TMDBApiKey)
 				if sResp, err := httpClient.Get(sUrl); err == nil {
 					defer sResp.Body.Close()
 					if sResp.StatusCode == http.StatusOK {
@@ -606,6 +906,8 @@ func fetchTMDBMeta(metaType, tmdbID string) (*Meta, error) {
 		Videos:       videos,
 		OriginalName: originalName,
 		Year:         year,
+		Ended:        detail.Status == "Ended" || detail.Status == "Canceled" || detail.Status == "Released",
+		AltNames:     detail.altNames(title, originalName),
 	}, nil
 }
 
@@ -632,9 +934,29 @@ func normalizeString(s string) string {
 
 // Stream represents a stream source.
 type Stream struct {
-	Name  string `json:"name"`
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	Name          string               `json:"name"`
+	Title         string               `json:"title"`
+	URL           string               `json:"url"`
+	BehaviorHints *StreamBehaviorHints `json:"behaviorHints,omitempty"`
+	Attrs         StreamAttributes     `json:"-"` // Internal use for ranking/filtering
+	Score         float64              `json:"-"` // Internal use for ranking
+	Subtitles     []Subtitle           `json:"-"` // Served by the subtitles resource
+}
+
+// StreamBehaviorHints tells Stremio how to play a stream.
+type StreamBehaviorHints struct {
+	BingeGroup   string              `json:"bingeGroup,omitempty"`  // Same group is auto-picked for the next episode
+	NotWebReady  bool                `json:"notWebReady,omitempty"` // Required when ProxyHeaders are set
+	Filename     string              `json:"filename,omitempty"`
+	VideoSize    int64               `json:"videoSize,omitempty"` // Exact file size in bytes, only if known
+	ProxyHeaders *StreamProxyHeaders `json:"proxyHeaders,omitempty"`
+}
+
+// StreamProxyHeaders are headers Stremio's streaming server adds when
+// fetching the stream.
+type StreamProxyHeaders struct {
+	Request  map[string]string `json:"request,omitempty"`
+	Response map[string]string `json:"response,omitempty"`
 }
 
 func handleStream(w http.ResponseWriter, r *http.Request) {
@@ -653,38 +975,32 @@ func handleStream(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
-	log.Printf("Handling Stream request for Type: %s, ID: %s", streamType, streamID)
+	slog.InfoContext(r.Context(), "Handling Stream request", "type", streamType, "id", streamID)
 
-	// We only support eztmdb prefixes for now
-	if !strings.HasPrefix(streamID, "eztmdb:") {
-		json.NewEncoder(w).Encode(map[string]interface{}{"streams": []Stream{}})
-		return
-	}
+	streams := findStreams(r.Context(), streamType, streamID)
+	json.NewEncoder(w).Encode(map[string]interface{}{"streams": proxyStreams(r, applyUserConfig(streams, userConfigFrom(r)))})
+}
 
-	// Parsing ID to get TMDB ID
-	// eztmdb:123
-	// eztmdb:123:1:1
-	idParts := strings.Split(streamID, ":")
-	if len(idParts) < 2 {
-		json.NewEncoder(w).Encode(map[string]interface{}{"streams": []Stream{}})
-		return
+// findStreams returns the ranked streams of all providers for a Stremio
+// stream ID, served from streamCache when possible.
+func findStreams(ctx context.Context, streamType, streamID string) []Stream {
+	cacheKey := streamType + ":" + streamID
+	if cached, ok := streamCache.Get(cacheKey); ok {
+		slog.InfoContext(ctx, "Serving cached streams", "id", streamID, "streams", len(cached))
+		return cached
 	}
-	tmdbID := idParts[1]
 
-	// Determine if it's a series
-	season := ""
-	episode := ""
-	if len(idParts) >= 4 {
-		season = idParts[2]
-		episode = idParts[3]
+	// Resolve eztmdb:/tt IDs to a TMDB ID (+ season/episode for series)
+	tmdbID, season, episode, err := resolveStremioID(streamType, streamID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to resolve stream ID", "id", streamID, "err", err)
+		return nil
 	}
 
 	// Fetch Meta to get the Title
-	meta, err := fetchTMDBMeta(streamType, tmdbID)
+	meta, err := fetchTMDBMeta(ctx, streamType, tmdbID)
 	if err != nil || meta == nil {
-		log.Printf("Failed to fetch meta for title: %v", err)
-		json.NewEncoder(w).Encode(map[string]interface{}{"streams": []Stream{}})
-		return
+		return nil
 	}
 
 	// Generate search queries
@@ -709,10 +1025,11 @@ func handleStream(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Add variations for Localized Name and Original Name
-	addVariations(meta.Name)
-	if meta.OriginalName != "" && meta.OriginalName != meta.Name {
-		addVariations(meta.OriginalName)
+	// Add variations for the Localized and Original names
+	for _, name := range []string{meta.Name, meta.OriginalName} {
+		if name != "" {
+			addVariations(name)
+		}
 	}
 
 	// Process Years
@@ -729,25 +1046,25 @@ func handleStream(w http.ResponseWriter, r *http.Request) {
 
 	var finalQueries []string
 
-	for _, q := range queries {
+	suffix := ""
 
-		// Base query (Name only) - only if it's unique enough?
+	if season != "" && episode != "" {
 
-		// Searching just "Wicked" might return too much, but Prehraj might handle it.
+		sInt, _ := strconv.Atoi(season)
 
-		// Let's include it.
+		eInt, _ := strconv.Atoi(episode)
 
-		suffix := ""
+		suffix = fmt.Sprintf(" S%02dE%02d", sInt, eInt)
 
-		if season != "" && episode != "" {
+	}
 
-			sInt, _ := strconv.Atoi(season)
+	for _, q := range queries {
 
-			eInt, _ := strconv.Atoi(episode)
+		// Base query (Name only) - only if it's unique enough?
 
-			suffix = fmt.Sprintf(" S%02dE%02d", sInt, eInt)
+		// Searching just "Wicked" might return too much, but Prehraj might handle it.
 
-		}
+		// Let's include it.
 
 		// Query with suffix (S01E01)
 
@@ -763,6 +1080,32 @@ func handleStream(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	// CZ/SK alternative names only as exact queries, each query is another
+	// search on the provider
+	for i, name := range meta.AltNames {
+		if i >= maxAltQueries {
+			break
+		}
+		finalQueries = append(finalQueries, name+suffix)
+	}
+
+	// Alternative episode numbering (1x05) and season packs (S01, 1. série).
+	// Only for the primary names, to keep the number of searches reasonable.
+	seasonNum, _ := strconv.Atoi(season)
+	episodeNum, _ := strconv.Atoi(episode)
+	if seasonNum > 0 && episodeNum > 0 {
+		for _, name := range []string{meta.Name, meta.OriginalName} {
+			if name == "" {
+				continue
+			}
+			finalQueries = append(finalQueries,
+				fmt.Sprintf("%s %dx%02d", name, seasonNum, episodeNum),
+				fmt.Sprintf("%s S%02d", name, seasonNum),
+				fmt.Sprintf("%s %d. série", name, seasonNum),
+			)
+		}
+	}
+
 	// Deduplicate queries
 
 	uniqueQueries := make(map[string]bool)
@@ -783,269 +1126,74 @@ func handleStream(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	log.Printf("Searching Prehraj.to with queries: [%s]", strings.Join(dedupedQueries, ", "))
-
-	// Collect results from all queries
-
-	var allResults []PrehrajResult
-
-	var resMu sync.Mutex
-
-	var wgSearch sync.WaitGroup
-
-	// Search concurrency limit to avoid being blocked and save resources
-	// When using Headless Chrome, we must limit this to 1 to prevent spawning multiple browsers
-	sem := make(chan struct{}, 1)
-
-	for _, q := range dedupedQueries {
-
-		wgSearch.Add(1)
-
-		go func(query string) {
-
-			defer wgSearch.Done()
-
-			sem <- struct{}{} // Acquire
-
-			defer func() { <-sem }() // Release
-
-			results, err := searchPrehraj(query)
-
-			if err == nil {
-
-				resMu.Lock()
-
-				allResults = append(allResults, results...)
-
-				resMu.Unlock()
-
-			} else {
-
-				log.Printf("Error searching %s: %v", query, err)
-
-			}
-
-		}(q)
-
-	}
-
-	wgSearch.Wait()
-
-	// Filter results based on year and titles
-
-	// We pass meta.Name and meta.OriginalName for relevance checking
-
-	names := []string{meta.Name}
-
-	if meta.OriginalName != "" && meta.OriginalName != meta.Name {
-
-		names = append(names, meta.OriginalName)
-
-	}
-
-	filteredResults := filterPrehrajResults(allResults, meta.Year, names...)
-
-	// Deduplicate results by URL
+	slog.InfoContext(ctx, "Searching providers", "title", meta.Name, "queries", dedupedQueries)
 
-	uniqueResults := make(map[string]PrehrajResult)
-
-	var orderedUniqueResults []PrehrajResult // To keep some order
-
-	for _, res := range filteredResults {
-
-		if _, exists := uniqueResults[res.URL]; !exists {
-
-			uniqueResults[res.URL] = res
-
-			orderedUniqueResults = append(orderedUniqueResults, res)
-
-		}
-
-	}
-
-	log.Printf("Found %d unique results", len(orderedUniqueResults))
+	// Relevance checking uses all names, including CZ/SK alternatives
+	names := meta.SearchNames()
 
+	// Fan out over all registered providers
 	var streams []Stream
-	var wgExtract sync.WaitGroup
 	var streamMu sync.Mutex
-
-	// Limit extraction to top 25 unique results
-	limit := 25
-	if len(orderedUniqueResults) < limit {
-		limit = len(orderedUniqueResults)
-	}
-
-	// Extraction concurrency limit
-	semExtract := make(chan struct{}, 5)
-
-	for i := 0; i < limit; i++ {
-		wgExtract.Add(1)
-		go func(res PrehrajResult) {
-			defer wgExtract.Done()
-			semExtract <- struct{}{}
-			defer func() { <-semExtract }()
-
-			extracted, err := extractPrehrajStreams(res.URL)
-			if err == nil && len(extracted) > 0 {
-				streamMu.Lock()
-				for _, s := range extracted {
-					// Parse Source Resolution from s.Name if present
-					sourceRes := ""
-					if strings.Contains(s.Name, "Source:") {
-						parts := strings.Split(s.Name, "Source:")
-						if len(parts) > 1 {
-							sourceRes = strings.TrimSuffix(strings.TrimSpace(parts[1]), ")")
-						}
-					}
-
-					// Clean up label (s.Title currently holds the label e.g. "1080p")
-					label := s.Title
-
-					// Format Name (Header)
-					s.Name = fmt.Sprintf("Prehraj.to ⚡ %s", label)
-
-					// Format Description (Title)
-					description := fmt.Sprintf("📂 %s\n💾 %s • ⏱️ %s", res.Title, res.Size, res.Duration)
-					if sourceRes != "" {
-						// Clean up source resolution for display (e.g. "3840 x 2160 px" -> "4K")
-						displaySource := sourceRes
-						if strings.Contains(sourceRes, "3840") || strings.Contains(sourceRes, "2160") {
-							displaySource = "4K"
-						} else if strings.Contains(sourceRes, "1920") || strings.Contains(sourceRes, "1080") {
-							displaySource = "1080p"
-						}
-						description += fmt.Sprintf("\n⚙️ Source: %s", displaySource)
-					}
-					s.Title = description
-
-					streams = append(streams, s)
-				}
-				streamMu.Unlock()
-			}
-		}(orderedUniqueResults[i])
-	}
-
-	wgExtract.Wait()
-
-	// Sorting logic
-	// Criteria: Source Resolution > Stream Resolution > Size > Filename contains Year
-
-	// Pre-compile regexes
-	// Source is now in Title: "⚙️ Source: 4K" or "Source: 3840 x 2160 px"
-	reSourceRes4K := regexp.MustCompile(`Source:\s*4K`)
-	reSourceRes1080 := regexp.MustCompile(`Source:\s*1080p`)
-	reSourceResRaw := regexp.MustCompile(`Source:.*x\s*(\d+)`)
-
-	// Stream Res in Name: "Prehraj ⚡ 1080p"
-	reStreamRes := regexp.MustCompile(`⚡\s+(\d{3,4})p`)
-
-	// Size in Title: "💾 56.37 GB"
-	reSize := regexp.MustCompile(`💾\s*(\d+(?:\.\d+)?)\s*(GB|MB|kB)`)
-
-	// Helper to get int resolution
-	getRes := func(name string, title string) int {
-		// Check Source in Title first
-		if reSourceRes4K.MatchString(title) {
-			return 2160
-		}
-		if reSourceRes1080.MatchString(title) {
-			return 1080
-		}
-		matches := reSourceResRaw.FindStringSubmatch(title)
-		if len(matches) > 1 {
-			if val, err := strconv.Atoi(matches[1]); err == nil {
-				return val
-			}
-		}
-		return 0
-	}
-
-	getStreamRes := func(name string) int {
-		matches := reStreamRes.FindStringSubmatch(name)
-		if len(matches) > 1 {
-			if val, err := strconv.Atoi(matches[1]); err == nil {
-				return val
-			}
-		}
-		return 0
-	}
-
-	// Helper to get size in MB
-	getSize := func(title string) float64 {
-		matches := reSize.FindStringSubmatch(title)
-		if len(matches) > 2 {
-			val, _ := strconv.ParseFloat(matches[1], 64)
-			unit := matches[2]
-			switch unit {
-			case "GB":
-				return val * 1024
-			case "MB":
-				return val
-			case "kB":
-				return val / 1024
-			}
+	var wgProviders sync.WaitGroup
+	for _, p := range providers {
+		if !p.Capabilities().supportsType(streamType) {
+			continue
 		}
-		return 0
+		wgProviders.Add(1)
+		go func(p StreamProvider) {
+			defer wgProviders.Done()
+			found := collectProviderStreams(ctx, p, dedupedQueries, meta, names, seasonNum, episodeNum)
+			streamMu.Lock()
+			streams = append(streams, found...)
+			streamMu.Unlock()
+		}(p)
 	}
+	wgProviders.Wait()
 
-	metaYear := meta.Year
-
-	sort.Slice(streams, func(i, j int) bool {
-		// 1. Source Resolution
-		srcResI := getRes(streams[i].Name, streams[i].Title)
-		srcResJ := getRes(streams[j].Name, streams[j].Title)
-		if srcResI != srcResJ {
-			return srcResI > srcResJ
-		}
-
-		// 2. Stream Resolution
-		strmResI := getStreamRes(streams[i].Name)
-		strmResJ := getStreamRes(streams[j].Name)
-		if strmResI != strmResJ {
-			return strmResI > strmResJ
-		}
-
-		// 3. Size
-		sizeI := getSize(streams[i].Title)
-		sizeJ := getSize(streams[j].Title)
-		if sizeI != sizeJ {
-			return sizeI > sizeJ
-		}
-
-		// 4. Filename contains Year
-		hasYearI := strings.Contains(streams[i].Title, metaYear)
-		hasYearJ := strings.Contains(streams[j].Title, metaYear)
-		if hasYearI != hasYearJ {
-			return hasYearI
-		}
+	// Rank by typed attributes (CZ/SK audio, resolution, size, ...), see scoring.go
+	rankStreams(streams, Config.ScoreWeights)
 
-		return false
-	})
-	json.NewEncoder(w).Encode(map[string]interface{}{"streams": streams})
+	streamCache.Set(cacheKey, streams, streamCacheTTL(streams))
+	return streams
 }
 
 func loadGenres() {
 	types := []string{"movie", "tv"}
 	for _, t := range types {
-		url := fmt.Sprintf("https://api.themoviedb.org/3/genre/%s/list?api_key=%s&language=cs-CZ", t, Config.TMDBApiKey)
+		url := fmt.Sprintf("%s/genre/%s/list?api_key=%s&language=cs-CZ", tmdbBaseURL, t, Config.TMDBApiKey)
 		resp, err := httpClient.Get(url)
 		if err != nil {
-			log.Printf("Failed to fetch genres for %s: %v", t, err)
+			slog.Warn("Failed to fetch genres", "type", t, "err", err)
 			continue
 		}
 		defer resp.Body.Close()
 
 		var genreResp TMDBGenreResponse
 		if err := json.NewDecoder(resp.Body).Decode(&genreResp); err == nil {
+			ids := make(map[string]int)
+			var names []string
 			for _, g := range genreResp.Genres {
 				genreMap[g.ID] = g.Name
+				ids[g.Name] = g.ID
+				names = append(names, g.Name)
 			}
+			genreIDs[t] = ids
+			genreNames[t] = names
 		}
 	}
-	log.Printf("Loaded %d genres", len(genreMap))
+	slog.Info("Loaded genres", "count", len(genreMap))
+}
+
+// TMDB list endpoints backing the extra catalogs (%s is the TMDB type).
+// Catalogs not listed here use /discover (or /search when searching).
+var tmdbListEndpoints = map[string]string{
+	"tmdb_trending_week": "trending/%s/week",
+	"tmdb_top_rated":     "%s/top_rated",
+	"tmdb_now_playing":   "%s/now_playing",
+	"tmdb_on_the_air":    "%s/on_the_air",
 }
 
-func fetchTMDBItems(catType string, page int, query string) ([]MetaPreview, error) {
+func fetchTMDBItems(ctx context.Context, catType string, catID string, page int, query string, genre string) ([]MetaPreview, error) {
 	if Config.TMDBApiKey == "" {
 		return nil, fmt.Errorf("TMDB API Key missing")
 	}
@@ -1057,13 +1205,25 @@ func fetchTMDBItems(catType string, page int, query string) ([]MetaPreview, erro
 
 	// Fetch the list of items
 	apiURL := ""
-	if query != "" {
-		log.Printf("Fetching TMDB items with search query: %s", query)
+	endpoint, isList := tmdbListEndpoints[catID]
+	if query == "" && isList {
+		slog.DebugContext(ctx, "Fetching TMDB list", "catalog", catID, "page", page)
+		apiURL = fmt.Sprintf("%s/%s?api_key=%s&language=cs-CZ&region=CZ&page=%d", tmdbBaseURL, fmt.Sprintf(endpoint, tmdbType), Config.TMDBApiKey, page)
+	} else if query != "" {
+		slog.DebugContext(ctx, "Searching TMDB", "query", query, "page", page)
 		encodedQuery := url.QueryEscape(query)
-		apiURL = fmt.Sprintf("https://api.themoviedb.org/3/search/%s?api_key=%s&language=cs-CZ&query=%s&page=%d&include_adult=false", tmdbType, Config.TMDBApiKey, encodedQuery, page)
+		apiURL = fmt.Sprintf("%s/search/%s?api_key=%s&language=cs-CZ&query=%s&page=%d&include_adult=false", tmdbBaseURL, tmdbType, Config.TMDBApiKey, encodedQuery, page)
 	} else {
-		log.Printf("Fetching TMDB items via discover for page %d", page)
-		apiURL = fmt.Sprintf("https://api.themoviedb.org/3/discover/%s?api_key=%s&language=cs-CZ&sort_by=popularity.desc&include_adult=false&page=%d", tmdbType, Config.TMDBApiKey, page)
+		slog.DebugContext(ctx, "Fetching TMDB discover", "page", page, "genre", genre)
+		apiURL = fmt.Sprintf("%s/discover/%s?api_key=%s&language=cs-CZ&sort_by=popularity.desc&include_adult=false&page=%d", tmdbBaseURL, tmdbType, Config.TMDBApiKey, page)
+		if genre != "" {
+			// Genre names come from the manifest options (Czech), map back to TMDB IDs
+			if id, ok := genreIDs[tmdbType][genre]; ok {
+				apiURL += fmt.Sprintf("&with_genres=%d", id)
+			} else {
+				return nil, fmt.Errorf("unknown %s genre: %s", tmdbType, genre)
+			}
+		}
 	}
 
 	resp, err := httpClient.Get(apiURL)
@@ -1109,7 +1269,7 @@ func fetchTMDBItems(catType string, page int, query string) ([]MetaPreview, erro
 			// We can still use cache to skip processing if we really wanted to, but we want freshness.
 			// We'll just fetch.
 
-			detailUrl := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d?api_key=%s&language=cs-CZ&append_to_response=credits,images&include_image_language=cs,sk,en,null", tmdbType, itemID, Config.TMDBApiKey)
+			detailUrl := fmt.Sprintf("%s/%s/%d?api_key=%s&language=cs-CZ&append_to_response=credits,images&include_image_language=cs,sk,en,null", tmdbBaseURL, tmdbType, itemID, Config.TMDBApiKey)
 			if detailResp, err := httpClient.Get(detailUrl); err == nil {
 				defer detailResp.Body.Close()
 				if detailResp.StatusCode == http.StatusOK {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// metaCacheEntry is the value stored in metaCache. Meta hides its internal
+// search fields from JSON, so they are kept alongside it for persistence.
+type metaCacheEntry struct {
+	Meta         *Meta    `json:"meta"`
+	OriginalName string   `json:"originalName,omitempty"`
+	Year         string   `json:"year,omitempty"`
+	Ended        bool     `json:"ended,omitempty"`
+	AltNames     []string `json:"altNames,omitempty"`
+}
+
+// metaCache holds fetchTMDBMeta results keyed by "type:tmdbID".
+var metaCache = newTTLCache[metaCacheEntry]("meta")
+
+// How often the meta cache is flushed to Config.MetaCacheFile
+const metaCacheSaveInterval = 5 * time.Minute
+
+// initMetaCache loads the persisted meta cache (if configured) and starts
+// the background goroutine that periodically writes it back to disk.
+func initMetaCache() {
+	path := Config.MetaCacheFile
+	if path == "" {
+		return
+	}
+	if err := metaCache.Load(path); err != nil {
+		slog.Warn("Failed to load meta cache", "path", path, "err", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(metaCacheSaveInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := metaCache.Save(path); err != nil {
+				slog.Warn("Failed to save meta cache", "path", path, "err", err)
+			}
+		}
+	}()
+}
+
+// fetchTMDBMeta returns metadata for a TMDB item, served from metaCache when
+// possible. The returned Meta is a copy and may be modified by the caller.
+func fetchTMDBMeta(ctx context.Context, metaType, tmdbID string) (*Meta, error) {
+	key := metaType + ":" + tmdbID
+	if e, ok := metaCache.Get(key); ok && e.Meta != nil {
+		meta := e.Meta.clone()
+		meta.OriginalName = e.OriginalName
+		meta.Year = e.Year
+		meta.Ended = e.Ended
+		meta.AltNames = slices.Clone(e.AltNames)
+		slog.DebugContext(ctx, "TMDB meta served from cache", "type", metaType, "tmdb_id", tmdbID)
+		return meta, nil
+	}
+
+	start := time.Now()
+	meta, err := loadTMDBMeta(metaType, tmdbID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch TMDB meta", "type", metaType, "tmdb_id", tmdbID, "err", err)
+		return nil, err
+	}
+	slog.DebugContext(ctx, "Fetched TMDB meta", "type", metaType, "tmdb_id", tmdbID, "name", meta.Name, "duration_ms", time.Since(start).Milliseconds())
+
+	ttl := Config.MetaCacheTTL
+	if meta.Ended {
+		ttl = Config.MetaCacheTTLEnded
+	}
+	cached := meta.clone()
+	metaCache.Set(key, metaCacheEntry{
+		Meta:         cached,
+		OriginalName: cached.OriginalName,
+		Year:         cached.Year,
+		Ended:        cached.Ended,
+		AltNames:     cached.AltNames,
+	}, ttl)
+
+	return meta, nil
+}
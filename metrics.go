@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served at /metrics.
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ezstremio_http_requests_total",
+		Help: "Addon HTTP requests by Stremio resource and status code.",
+	}, []string{"resource", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ezstremio_http_request_duration_seconds",
+		Help:    "Addon HTTP request latency by Stremio resource.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"resource"})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ezstremio_upstream_requests_total",
+		Help: "Requests to TMDB, Prehraj.to and the video CDN by status code (\"error\" for network errors).",
+	}, []string{"upstream", "code"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ezstremio_upstream_request_duration_seconds",
+		Help:    "Time until the response headers of upstream requests arrive.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ezstremio_cache_requests_total",
+		Help: "Cache lookups by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	providerResults = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ezstremio_provider_results",
+		Help:    "Results per stream request and provider: found by search, left after filtering, and streams extracted.",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100, 200},
+	}, []string{"provider", "stage"})
+
+	canaryOK = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ezstremio_scraper_canary_ok",
+		Help: "1 if the last scraper health check passed, 0 if a stage failed.",
+	}, nil)
+
+	canaryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ezstremio_scraper_canary_failures_total",
+		Help: "Failed scraper health checks by the stage that failed.",
+	}, []string{"stage"})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ezstremio_prehraj_logged_in",
+		Help: "1 if the Prehraj.to session is logged in.",
+	}, func() float64 {
+		if prehrajSession.LoggedIn() {
+			return 1
+		}
+		return 0
+	})
+)
+
+// Stremio resources and other routes used as the "resource" label. Anything
+// else is counted as "other" to keep the label set small.
+var metricResources = map[string]string{
+	"manifest.json":  "manifest",
+	"catalog":        "catalog",
+	"meta":           "meta",
+	"stream":         "stream",
+	"subtitles":      "subtitles",
+	"subtitle-files": "subtitle-files",
+	"proxy":          "proxy",
+	"configure":      "configure",
+	"health":         "health",
+	"metrics":        "metrics",
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// instrumentHandler counts and times requests per Stremio resource.
+func instrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		resource, ok := metricResources[first]
+		if !ok {
+			resource = "other"
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		httpDuration.WithLabelValues(resource).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(resource, strconv.Itoa(rec.code)).Inc()
+	})
+}
+
+// upstreamTransport records metrics for requests to an upstream service.
+type upstreamTransport struct {
+	upstream string
+	next     http.RoundTripper
+}
+
+// newUpstreamTransport wraps next (http.DefaultTransport if nil) to record
+// request counts and latencies labelled with upstream.
+func newUpstreamTransport(upstream string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &upstreamTransport{upstream: upstream, next: next}
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	upstreamDuration.WithLabelValues(t.upstream).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	upstreamRequests.WithLabelValues(t.upstream, code).Inc()
+	return resp, err
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"regexp"
@@ -18,168 +19,217 @@ import (
 	"github.com/go-rod/rod/lib/proto"
 )
 
+// Browser User-Agent sent to Prehraj.to (and its CDN via proxyHeaders)
+const prehrajUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36"
+
 var prehrajClient *http.Client
 
+// prehrajJar holds the session cookies. Re-login swaps its contents, so
+// prehrajClient never changes and in-flight requests are unaffected.
+var prehrajJar = newSwappableJar()
+
 func InitBrowser() {
 	if prehrajClient != nil {
 		return
 	}
 
+	prehrajClient = &http.Client{
+		Jar:       prehrajJar,
+		Timeout:   30 * time.Second,
+		Transport: newUpstreamTransport("prehraj", nil),
+	}
+
 	// Global Login
 	email := os.Getenv("PREHRAJ_EMAIL")
 	password := os.Getenv("PREHRAJ_PASSWORD")
 
 	if email != "" && password != "" {
-		fmt.Println("DEBUG: Performing global login...")
+		// Reuse the session saved by a previous run if it is still valid
+		if restoreSession() {
+			slog.Info("Restored saved Prehraj.to session")
+			prehrajSession.setLoggedIn(true)
+		} else {
+			slog.Info("Logging in to Prehraj.to")
+			cookies, err := prehrajLogin(email, password)
+			if err != nil {
+				slog.Error("Prehraj.to login failed", "err", err)
+			} else {
+				applySessionCookies(cookies)
+				prehrajSession.setLoggedIn(true)
+				slog.Info("Logged in to Prehraj.to")
+			}
+		}
+	}
+
+	// Fallback if login failed or not configured
+	if !prehrajSession.LoggedIn() {
+		slog.Info("Using Prehraj.to without login")
+	}
+}
 
-		// Launch headless browser for login only
-		path := "/usr/bin/chromium-browser"
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			path, _ = launcher.LookPath()
+// browserLogin logs into Prehraj.to with headless Chromium and returns the
+// session cookies.
+func browserLogin(email, password string) (cookies []*http.Cookie, err error) {
+	// rod's Must* helpers panic on failure; a failed re-login must not crash the addon
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("browser login panicked: %v", r)
 		}
+	}()
 
-		u := launcher.New().Bin(path).MustLaunch()
-		browser := rod.New().ControlURL(u).MustConnect()
-		defer browser.MustClose()
+	// Launch headless browser for login only
+	path := "/usr/bin/chromium-browser"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path, _ = launcher.LookPath()
+	}
 
-		page := browser.MustPage("https://prehraj.to/")
+	u := launcher.New().Bin(path).MustLaunch()
+	browser := rod.New().ControlURL(u).MustConnect()
+	defer browser.MustClose()
 
-		page.MustSetUserAgent(&proto.NetworkSetUserAgentOverride{
-			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
-		})
+	page := browser.MustPage(prehrajBaseURL + "/")
 
-		if err := page.Timeout(15 * time.Second).WaitLoad(); err != nil {
-			fmt.Printf("DEBUG: Login page load timeout: %v\n", err)
-		}
+	page.MustSetUserAgent(&proto.NetworkSetUserAgentOverride{
+		UserAgent: prehrajUserAgent,
+	})
+
+	if err := page.Timeout(15 * time.Second).WaitLoad(); err != nil {
+		slog.Debug("Login page load timeout", "err", err)
+	}
 
-		time.Sleep(2 * time.Second)
+	time.Sleep(2 * time.Second)
 
-		// Login Logic
-		loggedIn := false
-		inlineForm, err := page.Timeout(2 * time.Second).Element("#frm-homepageLoginForm-loginForm")
+	// Login Logic
+	loggedIn := false
+	inlineForm, err := page.Timeout(2 * time.Second).Element("#frm-homepageLoginForm-loginForm")
+	if err == nil {
+		slog.Debug("Found inline login form, filling")
+		inlineForm.MustElement(`input[name="email"]`).Input(email)
+		inlineForm.MustElement(`input[name="password"]`).Input(password)
+		go func() {
+			inlineForm.MustElement(`button[name="login"]`).Click(proto.InputMouseButtonLeft, 1)
+		}()
+		page.Timeout(10 * time.Second).WaitLoad()
+		slog.Debug("Login submitted via inline form")
+		loggedIn = true
+	} else {
+		slog.Debug("Inline form not found, checking for login button")
+		loginBtn, err := page.Timeout(2 * time.Second).Element(`[data-dialog-open="login"]`)
 		if err == nil {
-			fmt.Println("DEBUG: Found inline login form. Filling...")
-			inlineForm.MustElement(`input[name="email"]`).Input(email)
-			inlineForm.MustElement(`input[name="password"]`).Input(password)
-			go func() {
-				inlineForm.MustElement(`button[name="login"]`).Click(proto.InputMouseButtonLeft, 1)
-			}()
-			page.Timeout(10 * time.Second).WaitLoad()
-			fmt.Println("DEBUG: Login submitted via inline form.")
-			loggedIn = true
-		} else {
-			fmt.Println("DEBUG: Inline form not found, checking for login button...")
-			loginBtn, err := page.Timeout(2 * time.Second).Element(`[data-dialog-open="login"]`)
-			if err == nil {
-				fmt.Println("DEBUG: Login button found. Clicking...")
-				loginBtn.MustClick()
-				fmt.Println("DEBUG: Waiting for modal form...")
-				if err := page.Timeout(5*time.Second).WaitElementsMoreThan("#frm-loginDialog-login-loginForm", 0); err != nil {
-					fmt.Printf("DEBUG: Login modal did not appear: %v\n", err)
-				} else {
-					fmt.Println("DEBUG: Modal appeared. Filling...")
-					page.MustElement(`#frm-loginDialog-login-loginForm input[name="email"]`).Input(email)
-					page.MustElement(`#frm-loginDialog-login-loginForm input[name="password"]`).Input(password)
-					fmt.Println("DEBUG: Submitting modal form...")
-					wait := page.MustWaitNavigation()
-					page.MustElement(`#frm-loginDialog-login-loginForm button[name="login"]`).MustClick()
-					wait()
-					fmt.Println("DEBUG: Login submitted via modal.")
-					loggedIn = true
-				}
+			slog.Debug("Login button found, clicking")
+			loginBtn.MustClick()
+			slog.Debug("Waiting for modal form")
+			if err := page.Timeout(5*time.Second).WaitElementsMoreThan("#frm-loginDialog-login-loginForm", 0); err != nil {
+				slog.Debug("Login modal did not appear", "err", err)
 			} else {
-				fmt.Println("DEBUG: Login button not found. Assuming already logged in or layout changed.")
+				slog.Debug("Modal appeared, filling")
+				page.MustElement(`#frm-loginDialog-login-loginForm input[name="email"]`).Input(email)
+				page.MustElement(`#frm-loginDialog-login-loginForm input[name="password"]`).Input(password)
+				slog.Debug("Submitting modal form")
+				wait := page.MustWaitNavigation()
+				page.MustElement(`#frm-loginDialog-login-loginForm button[name="login"]`).MustClick()
+				wait()
+				slog.Debug("Login submitted via modal")
+				loggedIn = true
 			}
+		} else {
+			slog.Debug("Login button not found, assuming already logged in or layout changed")
 		}
+	}
 
-		if loggedIn {
-			// Extract cookies
-			cookies, err := page.Cookies(nil)
-			if err != nil {
-				fmt.Printf("DEBUG: Failed to get cookies: %v\n", err)
-			} else {
-				jar, _ := cookiejar.New(nil)
-				u, _ := url.Parse("https://prehraj.to")
-				jar.SetCookies(u, convertRodCookies(cookies))
-				prehrajClient = &http.Client{
-					Jar:     jar,
-					Timeout: 30 * time.Second,
-				}
-				fmt.Println("DEBUG: Cookies extracted and HTTP client initialized.")
-			}
-		}
+	if !loggedIn {
+		return nil, fmt.Errorf("login form not found")
 	}
 
-	// Fallback if login failed or not configured
-	if prehrajClient == nil {
-		fmt.Println("DEBUG: Initializing HTTP client without login.")
-		jar, _ := cookiejar.New(nil)
-		prehrajClient = &http.Client{
-			Jar:     jar,
-			Timeout: 30 * time.Second,
-		}
+	// Extract cookies
+	rodCookies, err := page.Cookies(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
 	}
+	return convertRodCookies(rodCookies), nil
 }
 
 func convertRodCookies(rodCookies []*proto.NetworkCookie) []*http.Cookie {
 	var cookies []*http.Cookie
 	for _, c := range rodCookies {
-		cookies = append(cookies, &http.Cookie{
-			Name:   c.Name,
-			Value:  c.Value,
-			Domain: c.Domain,
-			Path:   c.Path,
-			Secure: c.Secure,
-		})
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		// Session cookies have no expiry (-1)
+		if c.Expires > 0 {
+			cookie.Expires = c.Expires.Time()
+		}
+		cookies = append(cookies, cookie)
 	}
 	return cookies
 }
 
-// PrehrajResult represents a search result from Prehraj.to
-type PrehrajResult struct {
-	Title    string
-	Duration string
-	Size     string
-	URL      string
+func init() {
+	registerProvider(prehrajProvider{})
 }
 
-// searchPrehraj searches Prehraj.to using the persistent HTTP client
-func searchPrehraj(query string) ([]PrehrajResult, error) {
-	searchURL := fmt.Sprintf("https://prehraj.to/hledej/%s", url.PathEscape(query))
+// prehrajProvider is the StreamProvider for Prehraj.to.
+type prehrajProvider struct{}
 
-	if prehrajClient == nil {
-		InitBrowser()
+func (prehrajProvider) Name() string {
+	return "Prehraj.to"
+}
+
+func (prehrajProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		Movies:             true,
+		Series:             true,
+		SearchConcurrency:  1, // Avoid being blocked
+		ResolveConcurrency: 5,
+		MaxResolve:         25,
 	}
+}
 
-	fmt.Printf("DEBUG: Navigating to search: %s\n", searchURL)
+func (prehrajProvider) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	return searchPrehraj(ctx, query)
+}
 
-	req, err := http.NewRequest("GET", searchURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	// Mimic browser User-Agent
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36")
+func (prehrajProvider) Filter(ctx context.Context, results []SearchResult, metaYear string, metaNames ...string) []SearchResult {
+	return filterPrehrajResults(ctx, results, metaYear, metaNames...)
+}
 
-	resp, err := prehrajClient.Do(req)
+func (prehrajProvider) Resolve(ctx context.Context, res SearchResult) ([]Stream, error) {
+	return extractPrehrajStreams(ctx, res.URL)
+}
+
+// searchPrehraj searches Prehraj.to using the persistent HTTP client
+func searchPrehraj(ctx context.Context, query string) ([]SearchResult, error) {
+	searchURL := fmt.Sprintf("%s/hledej/%s", prehrajBaseURL, url.PathEscape(query))
+
+	slog.DebugContext(ctx, "Searching Prehraj.to", "query", query, "url", searchURL)
+
+	doc, _, err := fetchPrehrajPage(ctx, searchURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search returned status: %s", resp.Status)
+	results := parseSearchPage(ctx, doc)
+	if len(results) == 0 {
+		pageTitle := doc.Find("title").Text()
+		slog.DebugContext(ctx, "No Prehraj.to search results", "query", query, "page_title", pageTitle)
 	}
 
-	// Parse with GoQuery
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	return results, nil
+}
+
+// Search result links on Prehraj.to (based on research: a.video--link)
+const prehrajResultSelector = "a.video--link"
 
-	var results []PrehrajResult
+// parseSearchPage extracts the results from a search page.
+func parseSearchPage(ctx context.Context, doc *goquery.Document) []SearchResult {
+	var results []SearchResult
 
-	// Selector based on research: a.video--link
-	doc.Find("a.video--link").Each(func(i int, s *goquery.Selection) {
+	doc.Find(prehrajResultSelector).Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists {
 			return
@@ -189,7 +239,7 @@ func searchPrehraj(query string) ([]PrehrajResult, error) {
 
 	// Fallback: If no results found, try generic parsing of all links
 	if len(results) == 0 {
-		fmt.Println("DEBUG: Specific selector failed, trying generic fallback...")
+		slog.DebugContext(ctx, "Result selector matched nothing, trying generic fallback", "selector", prehrajResultSelector)
 		doc.Find("a").Each(func(i int, s *goquery.Selection) {
 			href, exists := s.Attr("href")
 			if !exists {
@@ -208,15 +258,10 @@ func searchPrehraj(query string) ([]PrehrajResult, error) {
 		})
 	}
 
-	if len(results) == 0 {
-		pageTitle := doc.Find("title").Text()
-		fmt.Printf("DEBUG: No results found for query '%s'. Page Title: '%s'.\n", query, pageTitle)
-	}
-
-	return results, nil
+	return results
 }
 
-func parseLink(s *goquery.Selection, href string, results *[]PrehrajResult) {
+func parseLink(s *goquery.Selection, href string, results *[]SearchResult) {
 	duration := ""
 	size := ""
 	cleanedTitle := ""
@@ -252,67 +297,96 @@ func parseLink(s *goquery.Selection, href string, results *[]PrehrajResult) {
 
 	if cleanedTitle != "" {
 		if !strings.HasPrefix(href, "http") {
-			href = "https://prehraj.to" + href
+			href = prehrajBaseURL + href
 		}
 
-		*results = append(*results, PrehrajResult{
+		*results = append(*results, SearchResult{
 			Title:    cleanedTitle,
 			Duration: duration,
 			Size:     size,
 			URL:      href,
+			Release:  parseRelease(cleanedTitle),
 		})
 	}
 }
 
-func extractPrehrajStreams(videoPageURL string) ([]Stream, error) {
+func extractPrehrajStreams(ctx context.Context, videoPageURL string) ([]Stream, error) {
+	doc, body, err := fetchPrehrajPage(ctx, videoPageURL)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch Prehraj.to video page", "url", videoPageURL, "err", err)
+		return nil, err
+	}
+	streams, err := parseVideoPage(doc, body, videoPageURL)
+	if err != nil {
+		slog.DebugContext(ctx, "No streams on Prehraj.to video page", "url", videoPageURL, "err", err)
+		return nil, err
+	}
+	slog.DebugContext(ctx, "Extracted Prehraj.to streams", "url", videoPageURL, "streams", len(streams))
+	return streams, nil
+}
+
+// fetchPrehrajPage downloads a Prehraj.to page with the session client and
+// returns the parsed document and the raw HTML. Pages showing the logged-out
+// UI trigger a re-login.
+func fetchPrehrajPage(ctx context.Context, pageURL string) (*goquery.Document, string, error) {
 	if prehrajClient == nil {
 		InitBrowser()
 	}
 
-	req, err := http.NewRequest("GET", videoPageURL, nil)
+	req, err := http.NewRequest("GET", pageURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36")
+	// Mimic browser User-Agent
+	req.Header.Set("User-Agent", prehrajUserAgent)
 
 	resp, err := prehrajClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Prehraj.to details returned status: %s", resp.Status)
+		return nil, "", fmt.Errorf("Prehraj.to returned status: %s", resp.Status)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	bodyString := string(bodyBytes)
+	body := string(bodyBytes)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	checkSession(ctx, doc)
+	return doc, body, nil
+}
 
+// parseVideoPage extracts the player sources and subtitle tracks from a
+// video page. body is the raw HTML that doc was parsed from.
+func parseVideoPage(doc *goquery.Document, body string, videoPageURL string) ([]Stream, error) {
 	// Regex to find "var sources = [...]"
 	re := regexp.MustCompile(`var sources = ([\s\S]*?]);`)
-	matches := re.FindStringSubmatch(bodyString)
+	matches := re.FindStringSubmatch(body)
 
 	// Parse HTML for "Rozlišení"
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(bodyString))
 	realResolution := ""
-	if err == nil {
-		doc.Find("li").Each(func(i int, s *goquery.Selection) {
-			if strings.Contains(s.Text(), "Rozlišení:") {
-				// The structure is <li><span>Rozlišení:</span><span>VALUE</span></li>
-				// We want the text of the second span, or just text after "Rozlišení:"
-				s.Find("span").Each(func(j int, span *goquery.Selection) {
-					if !strings.Contains(span.Text(), "Rozlišení:") {
-						realResolution = strings.TrimSpace(span.Text())
-					}
-				})
-			}
-		})
-	}
+	doc.Find("li").Each(func(i int, s *goquery.Selection) {
+		if strings.Contains(s.Text(), "Rozlišení:") {
+			// The structure is <li><span>Rozlišení:</span><span>VALUE</span></li>
+			// We want the text of the second span, or just text after "Rozlišení:"
+			s.Find("span").Each(func(j int, span *goquery.Selection) {
+				if !strings.Contains(span.Text(), "Rozlišení:") {
+					realResolution = strings.TrimSpace(span.Text())
+				}
+			})
+		}
+	})
 
 	var streams []Stream
+	subtitles := extractPrehrajSubtitles(body, videoPageURL)
 
 	if len(matches) > 1 {
 		jsonStr := matches[1]
@@ -336,15 +410,22 @@ func extractPrehrajStreams(videoPageURL string) ([]Stream, error) {
 					label = labelMatch[1]
 				}
 
-				name := "Prehraj.to " + label
-				if realResolution != "" {
-					name += fmt.Sprintf(" (Source: %s)", realResolution)
-				}
-
 				streams = append(streams, Stream{
-					Name:  name,
+					Name:  "Prehraj.to " + label,
 					Title: label,
 					URL:   url,
+					// The CDN may reject requests without the video page as Referer
+					BehaviorHints: &StreamBehaviorHints{
+						NotWebReady: true,
+						ProxyHeaders: &StreamProxyHeaders{
+							Request: map[string]string{
+								"Referer":    videoPageURL,
+								"User-Agent": prehrajUserAgent,
+							},
+						},
+					},
+					Attrs:     StreamAttributes{SourceHeight: parseSourceHeight(realResolution)},
+					Subtitles: subtitles,
 				})
 			}
 		}
@@ -357,9 +438,57 @@ func extractPrehrajStreams(videoPageURL string) ([]Stream, error) {
 	return streams, nil
 }
 
-func filterPrehrajResults(results []PrehrajResult, metaYear string, metaNames ...string) []PrehrajResult {
-	var filtered []PrehrajResult
-	yearReg := regexp.MustCompile(`\b(19|20)\d{2}\b`)
+// Subtitle tracks in the player config, e.g.
+// var tracks = [{ src: "/subtitles/123.vtt", srclang: "cs", label: "Čeština", kind: "captions" }];
+var (
+	reTracks     = regexp.MustCompile(`tracks\s*[=:]\s*(\[[\s\S]*?\])`)
+	reTrackFile  = regexp.MustCompile(`(?:src|file):\s*["']([^"']+)["']`)
+	reTrackLabel = regexp.MustCompile(`label:\s*["']([^"']+)["']`)
+	reTrackLang  = regexp.MustCompile(`(?:srclang|language|lang):\s*["']([^"']+)["']`)
+	reTrackKind  = regexp.MustCompile(`kind:\s*["']([^"']+)["']`)
+)
+
+// extractPrehrajSubtitles returns the subtitle tracks declared next to
+// "var sources" in a video page.
+func extractPrehrajSubtitles(body string, pageURL string) []Subtitle {
+	matches := reTracks.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		return nil
+	}
+	base, _ := url.Parse(pageURL)
+
+	var subtitles []Subtitle
+	for _, seg := range strings.Split(matches[1], "{") {
+		fileMatch := reTrackFile.FindStringSubmatch(seg)
+		if len(fileMatch) < 2 {
+			continue
+		}
+		// JW Player also lists thumbnail sprites and chapters as tracks
+		if kind := reTrackKind.FindStringSubmatch(seg); len(kind) > 1 && kind[1] != "captions" && kind[1] != "subtitles" {
+			continue
+		}
+
+		subURL := fileMatch[1]
+		if base != nil {
+			if ref, err := url.Parse(subURL); err == nil {
+				subURL = base.ResolveReference(ref).String()
+			}
+		}
+		label := ""
+		if m := reTrackLabel.FindStringSubmatch(seg); len(m) > 1 {
+			label = m[1]
+		}
+		lang := ""
+		if m := reTrackLang.FindStringSubmatch(seg); len(m) > 1 {
+			lang = m[1]
+		}
+		subtitles = append(subtitles, Subtitle{URL: subURL, Lang: subtitleLang(lang, label)})
+	}
+	return subtitles
+}
+
+func filterPrehrajResults(ctx context.Context, results []SearchResult, metaYear string, metaNames ...string) []SearchResult {
+	var filtered []SearchResult
 
 	targetYear := 0
 	if metaYear != "" {
@@ -367,48 +496,25 @@ func filterPrehrajResults(results []PrehrajResult, metaYear string, metaNames ..
 	}
 
 	for _, res := range results {
-		// 1. Year Check
-		if targetYear > 0 {
-			detectedYears := yearReg.FindAllString(res.Title, -1)
-			if len(detectedYears) > 0 {
-				yearMatch := false
-				for _, yStr := range detectedYears {
-					y, _ := strconv.Atoi(yStr)
-					// Strict match as requested
-					if y == targetYear {
-						yearMatch = true
-						break
-					}
-				}
-				if !yearMatch {
-					continue // Year detected but didn't match
-				}
-			}
+		// 1. Year Check (strict match as requested)
+		if targetYear > 0 && res.Release.Year > 0 && res.Release.Year != targetYear {
+			slog.DebugContext(ctx, "Dropped result: year mismatch", "title", res.Title, "year", res.Release.Year, "want", targetYear)
+			continue // Year detected but didn't match
 		}
 
-		// 2. Title Relevance Check (Basic)
-		// At least one of the metaNames (normalized) should appear in the result Title (normalized)
-		// This helps filter out completely unrelated items if the search engine was too fuzzy
+		// 2. Title Relevance Check
+		// The parsed release title must be similar enough to one of metaNames,
+		// so unrelated results from fuzzy searches ("Dobrá čarodějka" for
+		// "Čarodějka") are dropped.
 		if len(metaNames) > 0 {
-			matchFound := false
-			resTitleNorm := normalizeStringForFilter(res.Title)
-			for _, name := range metaNames {
-				nameNorm := normalizeStringForFilter(name)
-				if strings.Contains(resTitleNorm, nameNorm) {
-					matchFound = true
-					break
-				}
+			title := res.Release.Title
+			if title == "" {
+				title = res.Title
 			}
-			// If we searched for "Wicked" and result is "Something Else", we skip.
-			// However, Prehraj search usually returns things containing the query.
-			// But normalizing helps.
-			if !matchFound {
-				// Try partial match for long titles?
-				// For now, assume if the search engine returned it, it matched something.
-				// But we want to prevent "Dobrá čarodějka" matching "Čarodějka".
-				// "Dobrá čarodějka" contains "čarodějka".
-				// So containment check passes.
-				// The Year check is the primary strict filter here.
+			res.Similarity = titleSimilarity(title, metaNames)
+			if res.Similarity < Config.SimilarityThreshold {
+				slog.DebugContext(ctx, "Dropped result: title mismatch", "title", res.Title, "similarity", fmt.Sprintf("%.2f", res.Similarity), "threshold", Config.SimilarityThreshold)
+				continue
 			}
 		}
 
@@ -430,3 +536,32 @@ func normalizeStringForFilter(s string) string {
 	}
 	return strings.Join(strings.Fields(s), " ")
 }
+
+// Video dimensions in the "Rozlišení" value: "1920x800", "3840 x 2160 px"
+var reDimensions = regexp.MustCompile(`(\d{3,4})\s*[x×]\s*(\d{3,4})`)
+
+// Quality tiers by frame width. Widescreen encodes keep the width but crop
+// the height (1920x800 is 1080p), so the tier follows the larger dimension.
+var resolutionTiers = []struct{ width, height int }{
+	{3840, 2160}, {2560, 1440}, {1920, 1080}, {1280, 720}, {854, 480}, {640, 360},
+}
+
+// parseSourceHeight converts the "Rozlišení" value of a video page
+// ("3840 x 2160 px", "1920x800", "1080p") to a quality tier height, e.g.
+// 1080 for 1920x800 (0 if unknown).
+func parseSourceHeight(resolution string) int {
+	m := reDimensions.FindStringSubmatch(resolution)
+	if len(m) < 3 {
+		return parseLabelHeight(resolution)
+	}
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[2])
+	long, short := max(a, b), min(a, b)
+	for _, t := range resolutionTiers {
+		// Allow a few cropped pixels (1916x796, 3832x1600)
+		if long*100 >= t.width*95 || short >= t.height {
+			return t.height
+		}
+	}
+	return short
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestParseSourceHeight(t *testing.T) {
+	tests := []struct {
+		resolution string
+		want       int
+	}{
+		{"1920x1080", 1080},
+		{"1920x800", 1080},  // Widescreen
+		{"1916x796", 1080},  // Cropped a little
+		{"3840x2160", 2160}, // 4K
+		{"3840x1600", 2160}, // Widescreen 4K
+		{"3840 x 2160 px", 2160},
+		{"2560x1440", 1440},
+		{"1280x720", 720},
+		{"1280x536", 720},
+		{"1080x1920", 1080}, // Portrait
+		{"720x576", 480},    // PAL DVD
+		{"854x480", 480},
+		{"640x272", 360},
+		{"480x270", 270},
+		{"1080p", 1080},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseSourceHeight(tt.resolution); got != tt.want {
+			t.Errorf("parseSourceHeight(%q) = %d, want %d", tt.resolution, got, tt.want)
+		}
+	}
+}
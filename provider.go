@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SearchResult represents a single search hit on a hosting site.
+type SearchResult struct {
+	Title      string
+	Duration   string
+	Size       string
+	URL        string
+	Release    Release // Parsed from Title via parseRelease
+	Similarity float64 // Title similarity to the requested names, 0..1
+}
+
+// ProviderCapabilities describes what a StreamProvider supports and how hard
+// handleStream may hit it.
+type ProviderCapabilities struct {
+	Movies             bool
+	Series             bool
+	SearchConcurrency  int // Parallel searches (0 means 1)
+	ResolveConcurrency int // Parallel Resolve calls (0 means 1)
+	MaxResolve         int // Max search results resolved per request (0 means no limit)
+}
+
+// StreamProvider is a source of streams (e.g. a CZ/SK hosting site).
+// handleStream fans out over every registered provider.
+type StreamProvider interface {
+	// Name is shown in the stream list, e.g. "Prehraj.to".
+	Name() string
+	Capabilities() ProviderCapabilities
+	// Search returns raw results for a single query. ctx carries the request
+	// ID for logging.
+	Search(ctx context.Context, query string) ([]SearchResult, error)
+	// Filter drops results that don't match the requested title/year and
+	// sets SearchResult.Similarity on the ones it keeps.
+	Filter(ctx context.Context, results []SearchResult, metaYear string, metaNames ...string) []SearchResult
+	// Resolve turns a search result into playable streams.
+	// Stream.Title must hold the quality label (e.g. "1080p") and
+	// Stream.Attrs.SourceHeight the upload resolution, if known.
+	Resolve(ctx context.Context, res SearchResult) ([]Stream, error)
+}
+
+var providers []StreamProvider
+
+// registerProvider adds p to the providers used by handleStream.
+func registerProvider(p StreamProvider) {
+	providers = append(providers, p)
+}
+
+// supportsType reports whether the capabilities cover a Stremio type.
+func (c ProviderCapabilities) supportsType(streamType string) bool {
+	if streamType == "series" {
+		return c.Series
+	}
+	return c.Movies
+}
+
+// collectProviderStreams runs all queries against p, filters and resolves the
+// results and returns display-ready streams. For series, season and episode
+// are the requested episode (0 for movies).
+func collectProviderStreams(ctx context.Context, p StreamProvider, queries []string, meta *Meta, names []string, season, episode int) []Stream {
+	caps := p.Capabilities()
+
+	// Collect results from all queries
+	var allResults []SearchResult
+	var resMu sync.Mutex
+	var wgSearch sync.WaitGroup
+
+	// Search concurrency limit to avoid being blocked and save resources
+	sem := make(chan struct{}, max(caps.SearchConcurrency, 1))
+
+	for _, q := range queries {
+		wgSearch.Add(1)
+		go func(query string) {
+			defer wgSearch.Done()
+			sem <- struct{}{}        // Acquire
+			defer func() { <-sem }() // Release
+
+			results, err := p.Search(ctx, query)
+			if err == nil {
+				resMu.Lock()
+				allResults = append(allResults, results...)
+				resMu.Unlock()
+			} else {
+				slog.WarnContext(ctx, "Provider search failed", "provider", p.Name(), "query", query, "err", err)
+			}
+		}(q)
+	}
+	wgSearch.Wait()
+
+	// Filter results based on year and titles
+	filteredResults := p.Filter(ctx, allResults, meta.Year, names...)
+
+	// Drop other episodes; season packs and episode ranges containing the
+	// requested episode are kept
+	if season > 0 && episode > 0 {
+		var episodeResults []SearchResult
+		for _, res := range filteredResults {
+			if res.Release.containsEpisode(season, episode) {
+				episodeResults = append(episodeResults, res)
+			} else {
+				slog.DebugContext(ctx, "Dropped result: other episode", "title", res.Title, "want", fmt.Sprintf("S%02dE%02d", season, episode))
+			}
+		}
+		filteredResults = episodeResults
+	}
+
+	// Deduplicate results by URL
+	uniqueResults := make(map[string]SearchResult)
+	var orderedUniqueResults []SearchResult // To keep some order
+	for _, res := range filteredResults {
+		if _, exists := uniqueResults[res.URL]; !exists {
+			uniqueResults[res.URL] = res
+			orderedUniqueResults = append(orderedUniqueResults, res)
+		}
+	}
+
+	slog.InfoContext(ctx, "Provider results", "provider", p.Name(), "found", len(allResults), "unique", len(orderedUniqueResults))
+
+	var streams []Stream
+	var wgExtract sync.WaitGroup
+	var streamMu sync.Mutex
+
+	limit := len(orderedUniqueResults)
+	if caps.MaxResolve > 0 && caps.MaxResolve < limit {
+		limit = caps.MaxResolve
+	}
+
+	// Extraction concurrency limit
+	semExtract := make(chan struct{}, max(caps.ResolveConcurrency, 1))
+
+	for i := 0; i < limit; i++ {
+		wgExtract.Add(1)
+		go func(res SearchResult) {
+			defer wgExtract.Done()
+			semExtract <- struct{}{}
+			defer func() { <-semExtract }()
+
+			extracted, err := p.Resolve(ctx, res)
+			if err == nil && len(extracted) > 0 {
+				streamMu.Lock()
+				for _, s := range extracted {
+					streams = append(streams, formatProviderStream(p.Name(), res, s, meta))
+				}
+				streamMu.Unlock()
+			}
+		}(orderedUniqueResults[i])
+	}
+	wgExtract.Wait()
+
+	providerResults.WithLabelValues(p.Name(), "found").Observe(float64(len(allResults)))
+	providerResults.WithLabelValues(p.Name(), "filtered").Observe(float64(len(orderedUniqueResults)))
+	providerResults.WithLabelValues(p.Name(), "extracted").Observe(float64(len(streams)))
+
+	return streams
+}
+
+// Size as shown on hosting sites: "1.5 GB", "700 MB", "1,2 GB"
+var reSize = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*(GB|MB|kB)`)
+
+// parseSizeBytes converts a size string to bytes (0 if unknown).
+func parseSizeBytes(size string) int64 {
+	matches := reSize.FindStringSubmatch(size)
+	if len(matches) < 3 {
+		return 0
+	}
+	val, _ := strconv.ParseFloat(strings.ReplaceAll(matches[1], ",", "."), 64)
+	switch matches[2] {
+	case "GB":
+		val *= 1 << 30
+	case "MB":
+		val *= 1 << 20
+	case "kB":
+		val *= 1 << 10
+	}
+	return int64(val)
+}
+
+// Stream quality label as reported by the player config: "1080p", "720p"
+var reLabelHeight = regexp.MustCompile(`(\d{3,4})p`)
+
+// parseLabelHeight returns the vertical resolution of a quality label (0 if unknown).
+func parseLabelHeight(label string) int {
+	if m := reLabelHeight.FindStringSubmatch(label); len(m) > 1 {
+		height, _ := strconv.Atoi(m[1])
+		return height
+	}
+	return 0
+}
+
+// formatProviderStream fills the typed attributes of a resolved stream and
+// builds the Name (header) and Title (description) shown in Stremio.
+func formatProviderStream(providerName string, res SearchResult, s Stream, meta *Meta) Stream {
+	// s.Title holds the label from Resolve (e.g. "1080p")
+	label := s.Title
+
+	s.Attrs.Release = res.Release
+	s.Attrs.Height = parseLabelHeight(label)
+	s.Attrs.SizeBytes = parseSizeBytes(res.Size)
+	s.Attrs.YearMatch = meta.Year != "" && strconv.Itoa(res.Release.Year) == meta.Year
+	s.Attrs.Similarity = res.Similarity
+	s.Attrs.SingleEpisode = res.Release.Episode > 0 && res.Release.EpisodeEnd <= res.Release.Episode
+
+	// Behavior hints: keep the same provider and quality when autoplaying the next episode
+	hints := StreamBehaviorHints{}
+	if s.BehaviorHints != nil {
+		hints = *s.BehaviorHints
+	}
+	hints.BingeGroup = fmt.Sprintf("ezstremio-%s-%s", strings.ToLower(providerName), strings.ToLower(label))
+	hints.Filename = res.Title
+	// VideoSize stays unset: res.Size is rounded ("1.2 GB") and Stremio uses
+	// videoSize as the exact byte count to match subtitles by file hash
+	s.BehaviorHints = &hints
+
+	// Format Name (Header)
+	s.Name = fmt.Sprintf("%s ⚡ %s", providerName, label)
+
+	// Format Description (Title)
+	description := fmt.Sprintf("📂 %s\n💾 %s • ⏱️ %s", res.Title, res.Size, res.Duration)
+	if langs := formatLanguages(res.Release.AudioLangs, res.Release.SubtitleLangs); langs != "" {
+		description += "\n" + langs
+	}
+	if details := formatRelease(res.Release); details != "" {
+		description += "\n" + details
+	}
+	if pack := formatEpisodeRange(res.Release); pack != "" {
+		description += "\n" + pack
+	}
+	if s.Attrs.SourceHeight > 0 {
+		// e.g. 2160 -> "4K"
+		displaySource := fmt.Sprintf("%dp", s.Attrs.SourceHeight)
+		if s.Attrs.SourceHeight >= 2160 {
+			displaySource = "4K"
+		}
+		description += fmt.Sprintf("\n⚙️ Source: %s", displaySource)
+	}
+	s.Title = description
+
+	return s
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// proxyClient fetches proxied video data. It shares the Prehraj.to session
+// cookies but has no overall timeout, since a response body can be streamed
+// for the whole length of a movie.
+var proxyClient = &http.Client{
+	Jar: prehrajJar,
+	Transport: newUpstreamTransport("cdn", &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	}),
+}
+
+// Client request headers passed through to the upstream server
+var proxyRequestHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"}
+
+// Upstream response headers passed back to the client
+var proxyResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges",
+	"Last-Modified", "ETag", "Cache-Control",
+}
+
+// proxyToken is the signed payload of a /proxy/ URL.
+type proxyToken struct {
+	URL     string            `json:"u"`
+	Headers map[string]string `json:"h,omitempty"`
+	Expires int64             `json:"e"`
+}
+
+var errInvalidProxyToken = errors.New("invalid proxy token")
+
+// initProxySecret sets up the HMAC key for proxy tokens. Without PROXY_SECRET
+// a random key is generated, so tokens don't survive a restart. It runs once
+// before the server starts; handlers only read Config.ProxySecret.
+func initProxySecret() {
+	if len(Config.ProxySecret) > 0 {
+		return
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		slog.Error("Failed to generate proxy secret", "err", err)
+		os.Exit(1)
+	}
+	Config.ProxySecret = key
+}
+
+func signProxyPayload(payload string) string {
+	mac := hmac.New(sha256.New, Config.ProxySecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeProxyToken returns "payload.signature" for t.
+func encodeProxyToken(t proxyToken) string {
+	data, _ := json.Marshal(t)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signProxyPayload(payload)
+}
+
+// decodeProxyToken verifies the signature and expiry of a token.
+func decodeProxyToken(token string) (proxyToken, error) {
+	var t proxyToken
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signProxyPayload(payload))) {
+		return t, errInvalidProxyToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return t, errInvalidProxyToken
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, errInvalidProxyToken
+	}
+	if time.Now().Unix() > t.Expires {
+		return t, fmt.Errorf("proxy token expired")
+	}
+	return t, nil
+}
+
+// publicBaseURL returns the external address of the addon, from PUBLIC_URL
+// or the (possibly reverse proxied) request.
+func publicBaseURL(r *http.Request) string {
+	if Config.PublicURL != "" {
+		return strings.TrimRight(Config.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host
+}
+
+// proxyStreams rewrites stream URLs to go through /proxy/ when
+// Config.ProxyStreams is enabled. The proxy sends the required headers
+// itself, so the streams no longer need proxyHeaders.
+func proxyStreams(r *http.Request, streams []Stream) []Stream {
+	if !Config.ProxyStreams {
+		return streams
+	}
+
+	base := publicBaseURL(r)
+	expires := time.Now().Add(Config.ProxyTokenTTL).Unix()
+	out := make([]Stream, len(streams))
+	for i, s := range streams {
+		t := proxyToken{URL: s.URL, Expires: expires}
+		filename := "video.mp4"
+		if s.BehaviorHints != nil {
+			hints := *s.BehaviorHints
+			if hints.ProxyHeaders != nil {
+				t.Headers = hints.ProxyHeaders.Request
+			}
+			if hints.Filename != "" {
+				filename = hints.Filename
+			}
+			hints.ProxyHeaders = nil
+			hints.NotWebReady = false
+			s.BehaviorHints = &hints
+		}
+		// The trailing file name is ignored, it only helps players guess the format
+		s.URL = base + "/proxy/" + encodeProxyToken(t) + "/" + url.PathEscape(path.Base(filename))
+		out[i] = s
+	}
+	return out
+}
+
+// handleProxy streams /proxy/{token}[/{filename}] from the upstream URL in the
+// token, passing through Range requests so players can seek.
+func handleProxy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Headers", "Range, If-Range")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !Config.ProxyStreams {
+		http.NotFound(w, r)
+		return
+	}
+
+	token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/proxy/"), "/")
+	t, err := decodeProxyToken(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, t.URL, nil)
+	if err != nil {
+		http.Error(w, "bad upstream URL", http.StatusBadGateway)
+		return
+	}
+	req.Header.Set("User-Agent", prehrajUserAgent)
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	for _, h := range proxyRequestHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Proxy request failed", "err", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range proxyResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	// Client disconnects (seeking, closing the player) end the copy with an error
+	io.Copy(w, resp.Body)
+}
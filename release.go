@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Release holds the structured information parsed from a release (file) name
+// such as "Duna.Cast.Druha.2024.2160p.WEB-DL.HDR.x265.CZ.dabing.mkv".
+type Release struct {
+	Title         string   // Name with the release tags stripped
+	Year          int      // 0 if unknown
+	Season        int      // 0 if unknown
+	SeasonEnd     int      // Last season of a multi-season pack ("S01-S03"), else 0
+	Episode       int      // 0 if unknown or a season pack
+	EpisodeEnd    int      // Last episode of a multi-episode upload ("S01E01-E03"), else 0
+	SeasonPack    bool     // Whole season(s) rather than single episodes
+	Resolution    int      // Vertical resolution, e.g. 1080 (0 if unknown)
+	Source        string   // e.g. "BluRay", "WEB-DL", "CAM" ("" if unknown)
+	Codec         string   // e.g. "H.264", "H.265" ("" if unknown)
+	HDR           string   // "HDR", "HDR10", "HDR10+" or "DV" ("" if SDR/unknown)
+	AudioLangs    []string // See detectLanguages
+	SubtitleLangs []string // See detectLanguages
+}
+
+// Sources ordered from worst to best; the index is used as quality rank.
+var releaseSources = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"CAM", regexp.MustCompile(`(?i)\b(cam|camrip|hdcam)\b`)},
+	{"TS", regexp.MustCompile(`(?i)\b(ts|telesync|hdts|tc|telecine)\b`)},
+	{"DVDRip", regexp.MustCompile(`(?i)\b(dvdrip|dvd|dvd5|dvd9)\b`)},
+	{"HDTV", regexp.MustCompile(`(?i)\b(hdtv|tvrip|pdtv)\b`)},
+	{"WEBRip", regexp.MustCompile(`(?i)\b(webrip|web rip)\b`)},
+	{"WEB-DL", regexp.MustCompile(`(?i)\b(web dl|webdl|web)\b`)},
+	{"HDRip", regexp.MustCompile(`(?i)\b(hdrip|bdrip|brrip)\b`)},
+	{"BluRay", regexp.MustCompile(`(?i)\b(bluray|blu ray|bd)\b`)},
+	{"Remux", regexp.MustCompile(`(?i)\b(remux|bdremux)\b`)},
+}
+
+var releaseCodecs = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"H.265", regexp.MustCompile(`(?i)\b(x265|h265|h 265|hevc)\b`)},
+	{"H.264", regexp.MustCompile(`(?i)\b(x264|h264|h 264|avc)\b`)},
+	{"AV1", regexp.MustCompile(`(?i)\bav1\b`)},
+	{"XviD", regexp.MustCompile(`(?i)\b(xvid|divx)\b`)},
+}
+
+var releaseHDR = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"DV", regexp.MustCompile(`(?i)\b(dv|dovi|dolby vision)\b`)},
+	{"HDR10+", regexp.MustCompile(`(?i)\bhdr10(\+|plus)`)},
+	{"HDR10", regexp.MustCompile(`(?i)\bhdr10\b`)},
+	{"HDR", regexp.MustCompile(`(?i)\bhdr\b`)},
+}
+
+var (
+	reReleaseYear       = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+	reReleaseSxxExx     = regexp.MustCompile(`(?i)\bS(\d{1,2})\s?E(\d{1,3})(?:(?:\s?-\s?E?|E)(\d{1,3}))?\b`)
+	reReleaseNxNN       = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})(?:-(\d{2,3}))?\b`)
+	reReleaseEpisode    = regexp.MustCompile(`(?i)\b(?:E|Ep|Epizoda|D[ií]l)\s?(\d{1,3})\b|\b(\d{1,3})\s+d[ií]l\b`)
+	reReleaseSxx        = regexp.MustCompile(`(?i)\bS(\d{1,2})(?:\s?-\s?S?(\d{1,2}))?\b`)
+	reReleaseSeasonWord = regexp.MustCompile(`(?i)\b(\d{1,2})\s+(?:s[eé]rie|sez[oó]na|[řr]ada)\b|\b(?:season|s[eé]rie|sez[oó]na|[řr]ada)\s+(\d{1,2})\b`)
+	reReleaseComplete   = regexp.MustCompile(`(?i)\b(komplet|kompletn[ií]|complete)\b`)
+	reReleaseResolution = regexp.MustCompile(`(?i)\b(\d{3,4})[pi]\b`)
+	reReleaseDimensions = regexp.MustCompile(`(?i)\b\d{3,4}\s?x\s?(\d{3,4})\b`)
+	reRelease4K         = regexp.MustCompile(`(?i)\b(4k|uhd)\b`)
+	reReleaseExtension  = regexp.MustCompile(`(?i)\.(mkv|mp4|avi|m4v|wmv|mov|ts)$`)
+	reReleaseLangTag    = regexp.MustCompile(`(?i)\b(cz|sk|en|eng|czsk|cz&sk|dabing|dab|czdab|titulky|tit|cztit|subs?)\b`)
+)
+
+// parseRelease extracts structured fields from a release name.
+func parseRelease(name string) Release {
+	var r Release
+	r.AudioLangs, r.SubtitleLangs = detectLanguages(name)
+
+	// Work on a copy with separators turned into spaces so \b matches tags
+	clean := reReleaseExtension.ReplaceAllString(strings.TrimSpace(name), "")
+	clean = strings.NewReplacer(".", " ", "_", " ", "[", " ", "]", " ", "(", " ", ")", " ").Replace(clean)
+	clean = strings.Join(strings.Fields(clean), " ")
+
+	// titleEnd is where the first release tag starts; the title is what precedes it
+	titleEnd := len(clean)
+	markTag := func(loc []int) {
+		if loc != nil && loc[0] < titleEnd {
+			titleEnd = loc[0]
+		}
+	}
+
+	// Year: the last year that is not at the very start (titles like "1917" or
+	// "2012"). Only that one ends the title; earlier year-like numbers belong
+	// to it ("Blade Runner 2049 (2017)", "Wonder Woman 1984 2020").
+	var yearLoc []int
+	for _, loc := range reReleaseYear.FindAllStringSubmatchIndex(clean, -1) {
+		if loc[0] > 0 {
+			yearLoc = loc
+		}
+	}
+	if yearLoc != nil {
+		r.Year, _ = strconv.Atoi(clean[yearLoc[2]:yearLoc[3]])
+		markTag(yearLoc)
+	}
+
+	parseEpisodeTags(clean, &r, markTag)
+
+	if loc := reReleaseResolution.FindStringSubmatchIndex(clean); loc != nil {
+		r.Resolution, _ = strconv.Atoi(clean[loc[2]:loc[3]])
+		markTag(loc)
+	} else if loc := reReleaseDimensions.FindStringSubmatchIndex(clean); loc != nil {
+		r.Resolution, _ = strconv.Atoi(clean[loc[2]:loc[3]])
+		markTag(loc)
+	} else if loc := reRelease4K.FindStringIndex(clean); loc != nil {
+		r.Resolution = 2160
+		markTag(loc)
+	}
+
+	// Best matching source wins (e.g. "BluRay Remux" is a Remux)
+	for _, s := range releaseSources {
+		if loc := s.re.FindStringIndex(clean); loc != nil {
+			r.Source = s.name
+			markTag(loc)
+		}
+	}
+	for _, c := range releaseCodecs {
+		if loc := c.re.FindStringIndex(clean); loc != nil {
+			r.Codec = c.name
+			markTag(loc)
+			break
+		}
+	}
+	for _, h := range releaseHDR {
+		if loc := h.re.FindStringIndex(clean); loc != nil {
+			r.HDR = h.name
+			markTag(loc)
+			break
+		}
+	}
+
+	markTag(reReleaseLangTag.FindStringIndex(clean))
+
+	r.Title = strings.Trim(clean[:titleEnd], " -")
+	return r
+}
+
+// parseEpisodeTags fills the season/episode fields from the first matching
+// numbering scheme: S01E05(-E07), 1x05(-07), season packs (S01, S01-S03,
+// "1. série", "Season 1") and bare episodes (E05, "5. díl").
+func parseEpisodeTags(clean string, r *Release, markTag func([]int)) {
+	group := func(loc []int, n int) int {
+		if loc[2*n] < 0 {
+			return 0
+		}
+		v, _ := strconv.Atoi(clean[loc[2*n]:loc[2*n+1]])
+		return v
+	}
+
+	if loc := reReleaseSxxExx.FindStringSubmatchIndex(clean); loc != nil {
+		r.Season, r.Episode, r.EpisodeEnd = group(loc, 1), group(loc, 2), group(loc, 3)
+		markTag(loc)
+	} else if loc := reReleaseNxNN.FindStringSubmatchIndex(clean); loc != nil {
+		r.Season, r.Episode, r.EpisodeEnd = group(loc, 1), group(loc, 2), group(loc, 3)
+		markTag(loc)
+	} else if loc := reReleaseSxx.FindStringSubmatchIndex(clean); loc != nil {
+		r.Season, r.SeasonEnd = group(loc, 1), group(loc, 2)
+		markTag(loc)
+	} else if loc := reReleaseSeasonWord.FindStringSubmatchIndex(clean); loc != nil {
+		r.Season = max(group(loc, 1), group(loc, 2))
+		markTag(loc)
+	}
+
+	if r.Episode == 0 {
+		if loc := reReleaseEpisode.FindStringSubmatchIndex(clean); loc != nil {
+			r.Episode = max(group(loc, 1), group(loc, 2))
+			markTag(loc)
+		}
+	}
+	if loc := reReleaseComplete.FindStringIndex(clean); loc != nil {
+		markTag(loc)
+		if r.Episode == 0 {
+			r.SeasonPack = true
+		}
+	}
+	if r.Season > 0 && r.Episode == 0 {
+		r.SeasonPack = true
+	}
+	if r.EpisodeEnd <= r.Episode {
+		r.EpisodeEnd = 0
+	}
+	if r.SeasonEnd <= r.Season {
+		r.SeasonEnd = 0
+	}
+}
+
+// containsEpisode reports whether the release may contain the given episode:
+// it is that episode, a range or season pack including it, or carries no
+// (conflicting) numbering at all.
+func (r Release) containsEpisode(season, episode int) bool {
+	if r.Season > 0 && (season < r.Season || season > max(r.Season, r.SeasonEnd)) {
+		return false
+	}
+	if r.Episode > 0 && (episode < r.Episode || episode > max(r.Episode, r.EpisodeEnd)) {
+		return false
+	}
+	return true
+}
+
+// formatEpisodeRange labels season packs and multi-episode uploads for the
+// stream description, e.g. "📦 Season pack S01" or "📦 Episodes S01E01-E03".
+func formatEpisodeRange(r Release) string {
+	switch {
+	case r.SeasonPack && r.SeasonEnd > 0:
+		return fmt.Sprintf("📦 Season pack S%02d-S%02d", r.Season, r.SeasonEnd)
+	case r.SeasonPack && r.Season > 0:
+		return fmt.Sprintf("📦 Season pack S%02d", r.Season)
+	case r.SeasonPack:
+		return "📦 Season pack"
+	case r.EpisodeEnd > 0 && r.Season > 0:
+		return fmt.Sprintf("📦 Episodes S%02dE%02d-E%02d", r.Season, r.Episode, r.EpisodeEnd)
+	case r.EpisodeEnd > 0:
+		return fmt.Sprintf("📦 Episodes %d-%d", r.Episode, r.EpisodeEnd)
+	}
+	return ""
+}
+
+// sourceRank returns the quality rank of a release source (higher is better,
+// 0 if unknown).
+func sourceRank(source string) int {
+	for i, s := range releaseSources {
+		if s.name == source {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// formatRelease renders the technical release details for the stream
+// description, e.g. "🎞️ BluRay • H.265 • HDR".
+func formatRelease(r Release) string {
+	var parts []string
+	for _, p := range []string{r.Source, r.Codec, r.HDR} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "🎞️ " + strings.Join(parts, " • ")
+}
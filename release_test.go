@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		name       string
+		title      string
+		year       int
+		resolution int
+		source     string
+		codec      string
+		hdr        string
+	}{
+		{"Duna.Cast.Druha.2024.2160p.WEB-DL.HDR.x265.CZ.dabing.mkv", "Duna Cast Druha", 2024, 2160, "WEB-DL", "H.265", "HDR"},
+		{"Počátek (2010) 1080p BluRay x264 CZ", "Počátek", 2010, 1080, "BluRay", "H.264", ""},
+		{"Shrek 2 (2004) 720p", "Shrek 2", 2004, 720, "", "", ""},
+		{"Interstellar 4K UHD Remux DV", "Interstellar", 0, 2160, "Remux", "", "DV"},
+		{"Film 1920x800 CZ", "Film", 0, 800, "", "", ""},
+
+		// Year-like numbers in the title
+		{"Blade Runner 2049 (2017) CZ dabing 1080p", "Blade Runner 2049", 2017, 1080, "", "", ""},
+		{"Wonder Woman 1984 2020 CZ", "Wonder Woman 1984", 2020, 0, "", "", ""},
+
+		// Titles starting with a year
+		{"1917.2019.2160p.BluRay.HDR.x265.CZ.mkv", "1917", 2019, 2160, "BluRay", "H.265", "HDR"},
+		{"2012 (2009) CZ dabing", "2012", 2009, 0, "", "", ""},
+		{"1917 CZ", "1917", 0, 0, "", "", ""},
+	}
+	for _, tt := range tests {
+		r := parseRelease(tt.name)
+		if r.Title != tt.title || r.Year != tt.year || r.Resolution != tt.resolution ||
+			r.Source != tt.source || r.Codec != tt.codec || r.HDR != tt.hdr {
+			t.Errorf("parseRelease(%q) = %q %d %dp %q %q %q, want %q %d %dp %q %q %q", tt.name,
+				r.Title, r.Year, r.Resolution, r.Source, r.Codec, r.HDR,
+				tt.title, tt.year, tt.resolution, tt.source, tt.codec, tt.hdr)
+		}
+	}
+}
+
+func TestParseEpisodeTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		title      string
+		season     int
+		seasonEnd  int
+		episode    int
+		episodeEnd int
+		pack       bool
+	}{
+		{"Breaking.Bad.S01E02.720p.CZ.mkv", "Breaking Bad", 1, 0, 2, 0, false},
+		{"Breaking.Bad.S01E01-E03.1080p.WEB-DL.CZ.mkv", "Breaking Bad", 1, 0, 1, 3, false},
+		{"Breaking Bad S01E01E02 CZ", "Breaking Bad", 1, 0, 1, 2, false},
+		{"Breaking Bad 1x05 CZ dabing", "Breaking Bad", 1, 0, 5, 0, false},
+		{"Breaking Bad 2x01-03", "Breaking Bad", 2, 0, 1, 3, false},
+		{"Hra o trůny 1. série komplet CZ", "Hra o trůny", 1, 0, 0, 0, true},
+		{"Hra o trůny Season 2", "Hra o trůny", 2, 0, 0, 0, true},
+		{"Přátelé S01-S03 CZ", "Přátelé", 1, 3, 0, 0, true},
+		{"Přátelé S02 CZ", "Přátelé", 2, 0, 0, 0, true},
+		{"Ordinace v růžové zahradě 5. díl", "Ordinace v růžové zahradě", 0, 0, 5, 0, false},
+		{"Ordinace v růžové zahradě Díl 12", "Ordinace v růžové zahradě", 0, 0, 12, 0, false},
+		{"Comeback komplet CZ", "Comeback", 0, 0, 0, 0, true},
+		{"Počátek (2010) CZ", "Počátek", 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		r := parseRelease(tt.name)
+		if r.Title != tt.title || r.Season != tt.season || r.SeasonEnd != tt.seasonEnd ||
+			r.Episode != tt.episode || r.EpisodeEnd != tt.episodeEnd || r.SeasonPack != tt.pack {
+			t.Errorf("parseRelease(%q) = %q S%d-%d E%d-%d pack=%v, want %q S%d-%d E%d-%d pack=%v", tt.name,
+				r.Title, r.Season, r.SeasonEnd, r.Episode, r.EpisodeEnd, r.SeasonPack,
+				tt.title, tt.season, tt.seasonEnd, tt.episode, tt.episodeEnd, tt.pack)
+		}
+	}
+}
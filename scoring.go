@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StreamAttributes are the typed properties of a stream used for ranking
+// and filtering, independent of how Name/Title are formatted for display.
+type StreamAttributes struct {
+	Release       Release // Parsed release name
+	Height        int     // Stream label resolution (e.g. 1080 for "1080p")
+	SourceHeight  int     // Resolution of the uploaded file (0 if unknown)
+	SizeBytes     int64   // File size (0 if unknown)
+	YearMatch     bool    // Release year equals the TMDB year
+	Similarity    float64 // Title similarity to the TMDB names, 0..1
+	SingleEpisode bool    // Upload is exactly one episode (not a pack or range)
+}
+
+// ScoreWeights controls how much each attribute contributes to a stream's
+// score. Every attribute is normalized to 0..1 before weighting.
+type ScoreWeights struct {
+	Language   float64 // CZ/SK audio detected
+	SourceRes  float64 // Upload resolution (2160p = 1)
+	StreamRes  float64 // Stream label resolution (2160p = 1)
+	Quality    float64 // Release source (Remux = 1, CAM ≈ 0)
+	Size       float64 // File size (50 GB = 1)
+	Year       float64 // Release year matches
+	Similarity float64 // Title similarity
+	Episode    float64 // Single episode rather than a season pack / episode range
+}
+
+// defaultScoreWeights roughly reproduce the original ordering:
+// CZ/SK audio > source resolution > stream resolution > size > year,
+// with single episodes ahead of season packs for series. A matching year
+// weighs as much as 5 GB, so it only decides between similar sizes.
+// (Stream resolution mostly ranks the variants of one upload, which share
+// its size.)
+var defaultScoreWeights = ScoreWeights{
+	Language:   1000,
+	SourceRes:  100,
+	Similarity: 20,
+	StreamRes:  10,
+	Quality:    2,
+	Size:       5,
+	Year:       0.5,
+	Episode:    50,
+}
+
+// Heights and sizes at which the normalized attribute reaches 1
+const (
+	scoreMaxHeight    = 2160
+	scoreMaxSizeBytes = 50 << 30
+)
+
+// parseScoreWeights overrides the defaults with a list like
+// "language=1000,sourceRes=50,size=0".
+func parseScoreWeights(s string) ScoreWeights {
+	w := defaultScoreWeights
+	fields := map[string]*float64{
+		"language":   &w.Language,
+		"sourceres":  &w.SourceRes,
+		"streamres":  &w.StreamRes,
+		"quality":    &w.Quality,
+		"size":       &w.Size,
+		"year":       &w.Year,
+		"similarity": &w.Similarity,
+		"episode":    &w.Episode,
+	}
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		field, known := fields[strings.ToLower(strings.TrimSpace(key))]
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !known || err != nil {
+			slog.Warn("Ignoring invalid score weight", "value", part)
+			continue
+		}
+		*field = v
+	}
+	return w
+}
+
+// scoreStream computes the weighted score of a stream.
+func scoreStream(a StreamAttributes, w ScoreWeights) float64 {
+	normHeight := func(h int) float64 {
+		return min(float64(h)/scoreMaxHeight, 1)
+	}
+	boolScore := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	score := w.Language * boolScore(hasLocalAudio(a.Release.AudioLangs))
+	score += w.SourceRes * normHeight(a.SourceHeight)
+	score += w.StreamRes * normHeight(a.Height)
+	score += w.Quality * float64(sourceRank(a.Release.Source)) / float64(len(releaseSources))
+	score += w.Size * min(float64(a.SizeBytes)/scoreMaxSizeBytes, 1)
+	score += w.Year * boolScore(a.YearMatch)
+	score += w.Similarity * a.Similarity
+	score += w.Episode * boolScore(a.SingleEpisode)
+	return score
+}
+
+// rankStreams scores all streams and sorts them best first.
+func rankStreams(streams []Stream, w ScoreWeights) {
+	for i := range streams {
+		streams[i].Score = scoreStream(streams[i].Attrs, w)
+	}
+	sort.SliceStable(streams, func(i, j int) bool {
+		return streams[i].Score > streams[j].Score
+	})
+}
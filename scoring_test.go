@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestDefaultScoreWeightsOrder(t *testing.T) {
+	const gb = 1 << 30
+	base := StreamAttributes{Height: 1080, SourceHeight: 1080, SizeBytes: 2 * gb, Similarity: 1}
+	with := func(f func(a *StreamAttributes)) StreamAttributes {
+		a := base
+		f(&a)
+		return a
+	}
+
+	tests := []struct {
+		name          string
+		better, worse StreamAttributes
+	}{
+		{"CZ audio over resolution",
+			with(func(a *StreamAttributes) { a.Release.AudioLangs = []string{langCS} }),
+			with(func(a *StreamAttributes) { a.SourceHeight, a.Height, a.SizeBytes = 2160, 2160, 40*gb }),
+		},
+		{"source resolution over size",
+			with(func(a *StreamAttributes) { a.SourceHeight = 2160 }),
+			with(func(a *StreamAttributes) { a.SizeBytes = 50 * gb }),
+		},
+		{"size over year",
+			with(func(a *StreamAttributes) { a.SizeBytes = 10 * gb }),
+			with(func(a *StreamAttributes) { a.YearMatch = true }),
+		},
+		{"year between similar sizes",
+			with(func(a *StreamAttributes) { a.YearMatch = true }),
+			with(func(a *StreamAttributes) { a.SizeBytes = 3 * gb }),
+		},
+	}
+	for _, tt := range tests {
+		better := scoreStream(tt.better, defaultScoreWeights)
+		worse := scoreStream(tt.worse, defaultScoreWeights)
+		if better <= worse {
+			t.Errorf("%s: scores %.2f <= %.2f", tt.name, better, worse)
+		}
+	}
+}
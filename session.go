@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// swappableJar is an http.CookieJar whose underlying jar can be replaced
+// atomically (e.g. after re-login) while requests are in flight.
+type swappableJar struct {
+	mu  sync.RWMutex
+	jar http.CookieJar
+}
+
+func newSwappableJar() *swappableJar {
+	jar, _ := cookiejar.New(nil)
+	return &swappableJar{jar: jar}
+}
+
+func (j *swappableJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	j.jar.SetCookies(u, cookies)
+}
+
+func (j *swappableJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.jar.Cookies(u)
+}
+
+// Swap replaces the underlying jar.
+func (j *swappableJar) Swap(jar http.CookieJar) {
+	j.mu.Lock()
+	j.jar = jar
+	j.mu.Unlock()
+}
+
+// Minimum time between two re-login attempts, so bad credentials or a layout
+// change don't trigger a login on every request.
+const reloginCooldown = 10 * time.Minute
+
+// sessionState tracks whether the Prehraj.to session is logged in and runs
+// at most one re-login at a time.
+type sessionState struct {
+	mu          sync.Mutex
+	loggedIn    bool
+	relogin     chan struct{} // Non-nil while a re-login is running, closed when done
+	lastAttempt time.Time
+}
+
+var prehrajSession = &sessionState{}
+
+// LoggedIn reports whether the last login (or health check) succeeded.
+func (s *sessionState) LoggedIn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loggedIn
+}
+
+func (s *sessionState) setLoggedIn(v bool) {
+	s.mu.Lock()
+	s.loggedIn = v
+	s.mu.Unlock()
+}
+
+// prehrajCredentials returns the configured login, if any.
+func prehrajCredentials() (email, password string, ok bool) {
+	email = os.Getenv("PREHRAJ_EMAIL")
+	password = os.Getenv("PREHRAJ_PASSWORD")
+	return email, password, email != "" && password != ""
+}
+
+// Relogin starts a background re-login unless one is already running or the
+// cooldown has not passed. The returned channel is closed when the running
+// (or skipped) attempt is done, so callers may wait for it.
+func (s *sessionState) Relogin() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.relogin != nil {
+		return s.relogin
+	}
+	done := make(chan struct{})
+	email, password, ok := prehrajCredentials()
+	if !ok || time.Since(s.lastAttempt) < reloginCooldown {
+		close(done)
+		return done
+	}
+
+	s.relogin = done
+	s.lastAttempt = time.Now()
+	go func() {
+		slog.Info("Prehraj.to session expired, logging in again")
+		cookies, err := prehrajLogin(email, password)
+
+		s.mu.Lock()
+		if err != nil {
+			slog.Error("Prehraj.to re-login failed", "err", err)
+		} else {
+			applySessionCookies(cookies)
+			s.loggedIn = true
+			slog.Info("Prehraj.to re-login succeeded, session cookies replaced")
+		}
+		s.relogin = nil
+		s.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// checkSession inspects a fetched Prehraj.to page and triggers a background
+// re-login if the page shows we are logged out although credentials are set.
+func checkSession(ctx context.Context, doc *goquery.Document) {
+	if _, _, ok := prehrajCredentials(); !ok {
+		return
+	}
+	if !pageLooksLoggedOut(doc) {
+		return
+	}
+	if prehrajSession.LoggedIn() {
+		slog.WarnContext(ctx, "Page shows logged-out markers, Prehraj.to session expired")
+		prehrajSession.setLoggedIn(false)
+	}
+	prehrajSession.Relogin()
+}
+
+// pageLooksLoggedOut reports whether a page shows the anonymous login UI.
+func pageLooksLoggedOut(doc *goquery.Document) bool {
+	return doc.Find(`#frm-homepageLoginForm-loginForm, [data-dialog-open="login"], #frm-loginDialog-login-loginForm`).Length() > 0
+}
+
+// savedCookie is the on-disk form of a session cookie.
+type savedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// sessionCookieFile returns where session cookies are persisted ("" = disabled).
+func sessionCookieFile() string {
+	return os.Getenv("PREHRAJ_COOKIE_FILE")
+}
+
+// applySessionCookies installs freshly obtained login cookies and persists them.
+func applySessionCookies(cookies []*http.Cookie) {
+	jar, _ := cookiejar.New(nil)
+	base, _ := url.Parse(prehrajBaseURL)
+	jar.SetCookies(base, cookies)
+	prehrajJar.Swap(jar)
+
+	if path := sessionCookieFile(); path != "" {
+		if err := saveSessionCookies(path, cookies); err != nil {
+			slog.Warn("Failed to save session cookies", "path", path, "err", err)
+		}
+	}
+}
+
+// saveSessionCookies writes cookies to path, readable by the owner only.
+func saveSessionCookies(path string, cookies []*http.Cookie) error {
+	saved := make([]savedCookie, 0, len(cookies))
+	for _, c := range cookies {
+		saved = append(saved, savedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		})
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file, make sure it is private
+	return os.Chmod(path, 0o600)
+}
+
+// loadSessionCookies reads cookies saved by saveSessionCookies, skipping
+// expired ones.
+func loadSessionCookies(path string) ([]*http.Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var saved []savedCookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+
+	var cookies []*http.Cookie
+	now := time.Now()
+	for _, c := range saved {
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		})
+	}
+	return cookies, nil
+}
+
+// restoreSession loads saved cookies and checks with a single homepage
+// request that they still belong to a logged-in session.
+func restoreSession() bool {
+	path := sessionCookieFile()
+	if path == "" {
+		return false
+	}
+	cookies, err := loadSessionCookies(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to load session cookies", "path", path, "err", err)
+		}
+		return false
+	}
+	if len(cookies) == 0 {
+		return false
+	}
+
+	jar, _ := cookiejar.New(nil)
+	base, _ := url.Parse(prehrajBaseURL)
+	jar.SetCookies(base, cookies)
+	prehrajJar.Swap(jar)
+
+	if err := validateSession(); err != nil {
+		slog.Info("Saved Prehraj.to session is no longer valid", "err", err)
+		empty, _ := cookiejar.New(nil)
+		prehrajJar.Swap(empty)
+		return false
+	}
+	return true
+}
+
+// validateSession fetches the homepage and fails if it shows the login UI.
+func validateSession() error {
+	req, err := http.NewRequest("GET", prehrajBaseURL+"/", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", prehrajUserAgent)
+
+	resp, err := prehrajClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("homepage returned status: %s", resp.Status)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	if pageLooksLoggedOut(doc) {
+		return fmt.Errorf("homepage shows login form")
+	}
+	return nil
+}
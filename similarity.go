@@ -0,0 +1,160 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Two tokens count as the same word if their edit distance similarity is at
+// least this (e.g. "trony"/"truny", "dune"/"duna").
+const tokenMatchThreshold = 0.75
+
+// Separators between the Czech and original title of an upload:
+// "Počátek / Inception", "Počátek - Inception"
+var reTitleAlternatives = regexp.MustCompile(`\s*/\s*|\s+[-|]\s+`)
+
+// Filler words uploaders add to titles ("Počátek celý film", "Shrek film cz"),
+// ignored when comparing (normalized, without diacritics).
+var similarityStopWords = map[string]bool{
+	"cely": true, "film": true, "filmy": true, "online": true, "hd": true, "full": true,
+}
+
+// titleSimilarity returns how similar a release title is to the closest of
+// names, from 0 (unrelated) to 1 (same title). Each part of a dual title
+// ("Počátek / Inception") is compared on its own.
+func titleSimilarity(title string, names []string) float64 {
+	parts := append([]string{title}, reTitleAlternatives.Split(title, -1)...)
+	best := 0.0
+	for _, name := range names {
+		for _, part := range parts {
+			if s := nameSimilarity(part, name); s > best {
+				best = s
+			}
+		}
+	}
+	return best
+}
+
+// nameSimilarity scores the token overlap of title and name with fuzzy
+// token matching, as recall (share of name words found in the title) times
+// precision squared (share of title words belonging to the name). Extra
+// title words weigh more than missing ones: uploads often shorten a title,
+// but "Shrek Třetí" or "Dobrá čarodějka" are different works than "Shrek"
+// and "Čarodějka". Differing numbers ("Shrek 2") score 0.
+func nameSimilarity(title, name string) float64 {
+	titleTokens := similarityTokens(title)
+	nameTokens := similarityTokens(name)
+	if len(titleTokens) == 0 || len(nameTokens) == 0 {
+		return 0
+	}
+	if !sameNumbers(titleTokens, nameTokens) {
+		return 0
+	}
+
+	// Spacing differences ("Spiderman", "Spider-Man") count as one word
+	if tokensMatch(strings.Join(titleTokens, ""), strings.Join(nameTokens, "")) {
+		return editSimilarity(strings.Join(titleTokens, ""), strings.Join(nameTokens, ""))
+	}
+
+	// Token overlap: each name token may match one title token
+	used := make([]bool, len(titleTokens))
+	matched := 0
+	for _, nt := range nameTokens {
+		for i, tt := range titleTokens {
+			if !used[i] && tokensMatch(nt, tt) {
+				used[i] = true
+				matched++
+				break
+			}
+		}
+	}
+	recall := float64(matched) / float64(len(nameTokens))
+	precision := float64(matched) / float64(len(titleTokens))
+	return recall * precision * precision
+}
+
+// sameNumbers reports whether both token lists contain the same numbers, so
+// sequels and other parts of a series don't match each other.
+func sameNumbers(a, b []string) bool {
+	numbers := func(tokens []string) map[string]bool {
+		m := make(map[string]bool)
+		for _, t := range tokens {
+			if strings.IndexFunc(t, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+				m[t] = true
+			}
+		}
+		return m
+	}
+	na, nb := numbers(a), numbers(b)
+	if len(na) != len(nb) {
+		return false
+	}
+	for n := range na {
+		if !nb[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// similarityTokens normalizes s (diacritics, case, punctuation) into words,
+// leaving out stop words unless s consists of nothing else ("Film").
+func similarityTokens(s string) []string {
+	tokens := strings.FieldsFunc(normalizeStringForFilter(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var words []string
+	for _, t := range tokens {
+		if !similarityStopWords[t] {
+			words = append(words, t)
+		}
+	}
+	if len(words) == 0 {
+		return tokens
+	}
+	return words
+}
+
+// tokensMatch reports whether two words are equal, allowing small typos in
+// longer words. Numbers must match exactly ("2" is not "3").
+func tokensMatch(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) < 4 || len(b) < 4 {
+		return false
+	}
+	return editSimilarity(a, b) >= tokenMatchThreshold
+}
+
+// editSimilarity returns 1 - levenshtein(a, b) / max(len(a), len(b)).
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein computes the edit distance between two rune slices.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
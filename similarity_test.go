@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		title string
+		names []string
+		pass  bool // Kept with the default threshold of 0.5
+	}{
+		{"Počátek", []string{"Počátek", "Inception"}, true},
+		{"Pocatek", []string{"Počátek"}, true},
+		{"Inception", []string{"Počátek", "Inception"}, true},
+		{"Počátek / Inception", []string{"Počátek"}, true},
+		{"Počátek - Inception", []string{"Inception"}, true},
+		{"Harry Potter a kamen mudrcu", []string{"Harry Potter a Kámen mudrců"}, true},
+		{"Harry Potter Kamen mudrcu", []string{"Harry Potter a Kámen mudrců"}, true}, // Missing word
+		{"Hra o truny", []string{"Hra o trůny"}, true},
+		{"Hra o trony", []string{"Hra o trůny"}, true}, // Typo
+		{"Spiderman", []string{"Spider-Man"}, true},
+		{"Shrek", []string{"Shrek"}, true},
+		{"Shrek 2", []string{"Shrek 2"}, true},
+		{"1917", []string{"1917"}, true},
+		{"Počátek celý film", []string{"Počátek", "Inception"}, true},
+		{"Shrek film", []string{"Shrek"}, true},
+		{"Film", []string{"Film"}, true},
+
+		{"Dobrá čarodějka", []string{"Čarodějka"}, false},
+		{"Shrek 2", []string{"Shrek"}, false},
+		{"Shrek Třetí", []string{"Shrek"}, false},
+		{"Shrek", []string{"Shrek 2"}, false},
+		{"Kozí Václav", []string{"Počátek", "Inception"}, false},
+		{"Star Wars Andor", []string{"Star Wars"}, false},
+		{"", []string{"Shrek"}, false},
+	}
+	for _, tt := range tests {
+		got := titleSimilarity(tt.title, tt.names)
+		if pass := got >= 0.5; pass != tt.pass {
+			t.Errorf("titleSimilarity(%q, %q) = %.2f, want pass = %v", tt.title, tt.names, got, tt.pass)
+		}
+	}
+}
+
+func TestReleaseTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		release string
+		names   []string
+		pass    bool
+	}{
+		{"Blade Runner 2049 (2017) CZ dabing 1080p", []string{"Blade Runner 2049"}, true},
+		{"Wonder Woman 1984 2020 CZ", []string{"Wonder Woman 1984"}, true},
+		{"1917.2019.1080p.BluRay.CZ.mkv", []string{"1917"}, true},
+		{"Blade Runner 1982 CZ", []string{"Blade Runner 2049"}, false},
+		{"Počátek celý film CZ dabing", []string{"Počátek", "Inception"}, true},
+		{"Shrek film cz dabing", []string{"Shrek"}, true},
+		{"Dobrá čarodějka film CZ", []string{"Čarodějka"}, false},
+	}
+	for _, tt := range tests {
+		title := parseRelease(tt.release).Title
+		got := titleSimilarity(title, tt.names)
+		if pass := got >= 0.5; pass != tt.pass {
+			t.Errorf("titleSimilarity(%q, %q) = %.2f (from %q), want pass = %v", title, tt.names, got, tt.release, tt.pass)
+		}
+	}
+}
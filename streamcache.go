@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// streamCache holds sorted stream lists keyed by "type:streamID"
+// (e.g. "series:eztmdb:1399:1:2").
+var streamCache = newTTLCache[[]Stream]("stream")
+
+// Signed URLs are dropped from the cache this long before they actually expire,
+// so a player never receives a link that dies mid-request.
+const streamURLExpiryMargin = 2 * time.Minute
+
+// Empty results are cached only briefly, so repeated requests (e.g. Stremio
+// asking again right away) don't scrape again, but new uploads show up soon.
+const emptyStreamCacheTTL = time.Minute
+
+// Query parameters that carry a unix expiry timestamp in signed CDN URLs
+var streamURLExpiryParams = []string{"expires", "expire", "exp", "e"}
+
+// streamURLExpiry returns the expiry time encoded in a signed stream URL,
+// if it has one.
+func streamURLExpiry(rawURL string) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	q := u.Query()
+	for _, param := range streamURLExpiryParams {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		ts, err := strconv.ParseInt(v, 10, 64)
+		// Ignore values that are clearly not unix timestamps
+		if err != nil || ts < 1_000_000_000 {
+			continue
+		}
+		return time.Unix(ts, 0), true
+	}
+	return time.Time{}, false
+}
+
+// streamCacheTTL returns how long a stream list may be cached: at most
+// Config.StreamCacheTTL (emptyStreamCacheTTL if empty), and never past the
+// earliest signed URL expiry.
+func streamCacheTTL(streams []Stream) time.Duration {
+	ttl := Config.StreamCacheTTL
+	if len(streams) == 0 {
+		ttl = min(ttl, emptyStreamCacheTTL)
+	}
+	now := time.Now()
+	for _, s := range streams {
+		if exp, ok := streamURLExpiry(s.URL); ok {
+			if remaining := exp.Sub(now) - streamURLExpiryMargin; remaining < ttl {
+				ttl = remaining
+			}
+		}
+	}
+	return ttl
+}
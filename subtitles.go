@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Subtitle is a subtitle track in Stremio's subtitles format.
+type Subtitle struct {
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+	Lang string `json:"lang"` // ISO 639-2 code ("cze") or a display label
+}
+
+// Stremio expects ISO 639-2 codes to show a language name in the player
+var subtitleLangCodes = map[string]string{
+	langCS: "cze",
+	langSK: "slo",
+	langEN: "eng",
+}
+
+// Subtitle file extensions served from SUBTITLES_DIR
+var subtitleExtensions = map[string]bool{".srt": true, ".vtt": true, ".sub": true, ".ass": true}
+
+// subtitleLang maps a track's language code or label ("cs", "Čeština",
+// "CZ titulky") to an ISO 639-2 code. Unknown languages keep their label.
+func subtitleLang(code string, label string) string {
+	langs := parseLanguageList(code)
+	if len(langs) == 0 {
+		audio, subs := detectLanguages(label)
+		langs = append(subs, audio...)
+	}
+	if len(langs) > 0 {
+		if iso, ok := subtitleLangCodes[langs[0]]; ok {
+			return iso
+		}
+	}
+	if label != "" {
+		return label
+	}
+	return code
+}
+
+// handleSubtitles serves /subtitles/{type}/{id}[/{extra}].json.
+//
+// Prehraj.to tracks come from the streams found for the same video ID (the
+// player asks for subtitles after picking a stream, so they are cached).
+// When Stremio sends the file name of the playing stream only its tracks
+// are returned.
+func handleSubtitles(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, ".json"), "/")
+	if len(parts) < 4 {
+		http.NotFound(w, r)
+		return
+	}
+
+	subType := parts[2]
+	subID := parts[3]
+	filename := ""
+	// Parse extras from the escaped path, file names may contain "&" or "+"
+	if escaped := strings.Split(strings.TrimSuffix(r.URL.EscapedPath(), ".json"), "/"); len(escaped) > 4 {
+		if extra, err := url.ParseQuery(escaped[4]); err == nil {
+			filename = extra.Get("filename")
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	slog.InfoContext(r.Context(), "Handling Subtitles request", "type", subType, "id", subID)
+
+	subtitles := localSubtitles(r, subID)
+
+	// Cached by the stream request just before; never scraped from here
+	streams, _ := streamCache.Get(subType + ":" + subID)
+	var playing []Stream
+	for _, s := range streams {
+		if filename != "" && s.BehaviorHints != nil && s.BehaviorHints.Filename == filename {
+			playing = append(playing, s)
+		}
+	}
+	if len(playing) > 0 {
+		streams = playing
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range streams {
+		for _, sub := range s.Subtitles {
+			if seen[sub.URL] {
+				continue
+			}
+			seen[sub.URL] = true
+			sub.ID = fmt.Sprintf("prehraj-%d", len(seen))
+			subtitles = append(subtitles, sub)
+		}
+	}
+
+	if subtitles == nil {
+		subtitles = []Subtitle{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"subtitles": subtitles})
+}
+
+// localSubtitleName returns the file name prefix for a video ID in
+// SUBTITLES_DIR, e.g. "tt0903747_1_2" for "tt0903747:1:2".
+func localSubtitleName(id string) string {
+	return strings.ReplaceAll(id, ":", "_")
+}
+
+// localSubtitles lists files in SUBTITLES_DIR named
+// "{id}.{lang}[.anything].{srt|vtt|...}", e.g. "tt0903747_1_2.cze.srt".
+func localSubtitles(r *http.Request, id string) []Subtitle {
+	if Config.SubtitlesDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(Config.SubtitlesDir)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Failed to read subtitles dir", "path", Config.SubtitlesDir, "err", err)
+		return nil
+	}
+
+	prefix := localSubtitleName(id) + "."
+	base := publicBaseURL(r)
+	var subtitles []Subtitle
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !subtitleExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		lang, _, _ := strings.Cut(strings.TrimPrefix(name, prefix), ".")
+		subtitles = append(subtitles, Subtitle{
+			ID:   "local-" + name,
+			URL:  base + "/subtitle-files/" + url.PathEscape(name),
+			Lang: subtitleLang(lang, lang),
+		})
+	}
+	return subtitles
+}
+
+// handleSubtitleFile serves a file from SUBTITLES_DIR.
+func handleSubtitleFile(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Base(strings.TrimPrefix(r.URL.Path, "/subtitle-files/"))
+	if Config.SubtitlesDir == "" || !subtitleExtensions[strings.ToLower(filepath.Ext(name))] {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeFile(w, r, filepath.Join(Config.SubtitlesDir, name))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// UserConfig holds per-user preferences. Stremio passes it as the first path
+// segment of every addon URL (/{config}/manifest.json, /{config}/stream/...),
+// encoded as base64url JSON.
+type UserConfig struct {
+	Audio     []string `json:"audio,omitempty"`     // Only show streams with these audio languages
+	Prefer    string   `json:"prefer,omitempty"`    // Audio language listed first ("cs" or "sk")
+	MinHeight int      `json:"minHeight,omitempty"` // Minimum stream resolution, e.g. 1080
+	MaxSizeGB float64  `json:"maxSizeGB,omitempty"` // Maximum file size (0 = no limit)
+}
+
+type userConfigKey struct{}
+
+// Top level paths that are addon routes rather than a config segment
+var addonRoutes = map[string]bool{
+	"manifest.json":  true,
+	"catalog":        true,
+	"meta":           true,
+	"stream":         true,
+	"configure":      true,
+	"proxy":          true,
+	"subtitles":      true,
+	"subtitle-files": true,
+	"health":         true,
+	"metrics":        true,
+}
+
+// defaultUserConfig is used when the request carries no config segment.
+func defaultUserConfig() UserConfig {
+	return UserConfig{Audio: Config.AudioFilter}
+}
+
+// encodeUserConfig returns the URL path segment for cfg.
+func encodeUserConfig(cfg UserConfig) string {
+	data, _ := json.Marshal(cfg)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserConfig parses a config path segment.
+func decodeUserConfig(segment string) (UserConfig, error) {
+	var cfg UserConfig
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid config encoding: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Normalize languages so "cz" and "cs" both work
+	cfg.Audio = parseLanguageList(strings.Join(cfg.Audio, ","))
+	if prefer := parseLanguageList(cfg.Prefer); len(prefer) > 0 {
+		cfg.Prefer = prefer[0]
+	} else {
+		cfg.Prefer = ""
+	}
+	return cfg, nil
+}
+
+// withUserConfig strips an optional config segment from the URL path and
+// stores the decoded UserConfig in the request context, so handlers keep
+// parsing /stream/{type}/{id}.json regardless of configuration.
+func withUserConfig(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		if len(segments) == 2 && segments[0] != "" && !addonRoutes[segments[0]] {
+			cfg, err := decodeUserConfig(segments[0])
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userConfigKey{}, cfg))
+			// Strip the escaped form too, catalog and subtitle extras are
+			// parsed from it ("search=Tom%20%26%20Jerry")
+			_, rawRest, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+			r.URL.Path = "/" + segments[1]
+			r.URL.RawPath = "/" + rawRest
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userConfigFrom returns the config of the request, or the server defaults.
+func userConfigFrom(r *http.Request) UserConfig {
+	if cfg, ok := r.Context().Value(userConfigKey{}).(UserConfig); ok {
+		return cfg
+	}
+	return defaultUserConfig()
+}
+
+// wantsDubOnly reports whether the user only accepts CZ/SK audio.
+func (c UserConfig) wantsDubOnly() bool {
+	return len(c.Audio) > 0 && !matchesAudioFilter([]string{langEN}, c.Audio)
+}
+
+// applyUserConfig filters streams according to cfg and moves the preferred
+// audio language to the front, keeping the existing order otherwise.
+func applyUserConfig(streams []Stream, cfg UserConfig) []Stream {
+	filtered := []Stream{}
+	for _, s := range streams {
+		if !matchesAudioFilter(s.Attrs.Release.AudioLangs, cfg.Audio) {
+			continue
+		}
+		if cfg.MinHeight > 0 && s.Attrs.Height < cfg.MinHeight {
+			continue
+		}
+		if cfg.MaxSizeGB > 0 && float64(s.Attrs.SizeBytes) > cfg.MaxSizeGB*(1<<30) {
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+
+	if cfg.Prefer != "" {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			prefI := matchesAudioFilter(filtered[i].Attrs.Release.AudioLangs, []string{cfg.Prefer})
+			prefJ := matchesAudioFilter(filtered[j].Attrs.Release.AudioLangs, []string{cfg.Prefer})
+			return prefI && !prefJ
+		})
+	}
+	return filtered
+}
+
+// filterUnavailable drops catalog items the availability index knows have no
+// dubbed streams. Items that were not probed yet are kept.
+func filterUnavailable(catType string, items []MetaPreview) []MetaPreview {
+	filtered := []MetaPreview{}
+	for _, item := range items {
+		if e, ok := availabilityIndex.Get(catType + ":" + item.ID); ok && !e.Available {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}